@@ -0,0 +1,101 @@
+package wsi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-on/lib/misc/meta"
+)
+
+// column is a sql column of a mapper struct
+type column struct {
+	Name  string
+	Field reflect.StructField
+
+	// Options are the comma separated values that follow the name inside the sql tag
+	Options []string
+}
+
+func (c column) hasOption(option string) bool {
+	for _, o := range c.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// columns maps the column names of a mapper struct to their columns
+type columns map[string]column
+
+func (c columns) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+// lookup returns the column for the given column or field name. Exact matches win, otherwise
+// the names are compared case-insensitively, so that e.g. the path parameter id finds the field Id.
+func (c columns) lookup(name string) (column, bool) {
+	if col, ok := c[name]; ok {
+		return col, true
+	}
+	if col, ok := c.byField(name); ok {
+		return col, true
+	}
+	for _, col := range c.ordered() {
+		if strings.EqualFold(col.Name, name) || strings.EqualFold(col.Field.Name, name) {
+			return col, true
+		}
+	}
+	return column{}, false
+}
+
+// byField returns the column of the field with the given name
+func (c columns) byField(name string) (column, bool) {
+	for _, col := range c {
+		if col.Field.Name == name {
+			return col, true
+		}
+	}
+	return column{}, false
+}
+
+// ordered returns the columns in the order of the struct fields
+func (c columns) ordered() []column {
+	cols := make([]column, 0, len(c))
+	for _, col := range c {
+		cols = append(cols, col)
+	}
+	sort.Sort(byFieldIndex(cols))
+	return cols
+}
+
+type byFieldIndex []column
+
+func (b byFieldIndex) Len() int           { return len(b) }
+func (b byFieldIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byFieldIndex) Less(i, j int) bool { return b[i].Field.Index[0] < b[j].Field.Index[0] }
+
+// sqlColumns returns the columns of the struct structPtr points to.
+// It follows the same rules as MapSQL and ColumnPtrs: the column name is the name given in the sql tag
+// or the field name if the tag has no name. Fields tagged with "-" and unexported fields are skipped.
+func sqlColumns(structPtr interface{}) (columns, error) {
+	s, err := meta.StructByValue(reflect.ValueOf(structPtr))
+	if err != nil {
+		return nil, err
+	}
+	cols := columns{}
+	s.EachTagWithEmpty("sql", func(f *meta.Field, tagVal string) {
+		if f.Type.PkgPath != "" {
+			return
+		}
+		tvs := strings.Split(tagVal, ",")
+		c := column{Name: tvs[0], Field: f.Type, Options: tvs[1:]}
+		if c.Name == "" {
+			c.Name = f.Type.Name
+		}
+		cols[c.Name] = c
+	})
+	return cols, nil
+}
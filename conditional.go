@@ -0,0 +1,154 @@
+package wsi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionFunc returns a cheap version of the data that is served for the request, e.g. the
+// last change of a table. It is used as weak ETag, so that a request with a matching If-None-Match header
+// is answered with http.StatusNotModified without running the query. It must not write to the response.
+type VersionFunc func(r *http.Request) (string, error)
+
+// weakETag returns the weak entity tag for the given version
+func weakETag(version string) string {
+	return `W/"` + strings.Replace(version, `"`, "", -1) + `"`
+}
+
+// rowsETag returns a weak entity tag over the ETags of the given rows and the requested fields and total
+func rowsETag(rows []interface{}, fields []string, total int) (string, error) {
+	h := sha256.New()
+	for _, row := range rows {
+		etag, err := ETag(row)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(etag))
+	}
+	h.Write([]byte(strings.Join(fields, ",") + ";" + strconv.Itoa(total)))
+	return weakETag(hex.EncodeToString(h.Sum(nil)[:16])), nil
+}
+
+// lastModified returns the time of the column with the lastmodified option
+// (e.g. `sql:"updated_at,lastmodified"`) of the given mapper. The column must be a time.Time or *time.Time.
+func lastModified(mapper interface{}) (t time.Time, ok bool) {
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return
+	}
+	for _, col := range cols {
+		if !col.hasOption("lastmodified") {
+			continue
+		}
+		switch v := reflect.Indirect(reflect.ValueOf(mapper)).FieldByIndex(col.Field.Index).Interface().(type) {
+		case time.Time:
+			return v, !v.IsZero()
+		case *time.Time:
+			if v != nil {
+				return *v, !v.IsZero()
+			}
+		}
+	}
+	return
+}
+
+// matchNoneETag reports if the given If-None-Match header matches the given entity tag.
+// Weak and strong tags are compared by their opaque values (weak comparison, RFC 7232).
+func matchNoneETag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports if the validators of the GET or HEAD request match the given entity tag
+// or last modification. If-Modified-Since is only evaluated without If-None-Match.
+func notModified(r *http.Request, etag string, lastMod time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchNoneETag(inm, etag)
+	}
+	if lastMod.IsZero() {
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastMod.Truncate(time.Second).After(ims)
+}
+
+// setValidators sets the ETag and Last-Modified headers, if they are known
+func setValidators(w http.ResponseWriter, etag string, lastMod time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastMod.IsZero() {
+		w.Header().Set("Last-Modified", lastMod.UTC().Format(http.TimeFormat))
+	}
+}
+
+// rowIterator returns the next row or nil, if there are no rows left
+type rowIterator func() (interface{}, error)
+
+// scanRows returns a rowIterator that scans the rows of the given scanner into new mappers
+func scanRows(sc Scanner, mapperFn func() interface{}) rowIterator {
+	return func() (interface{}, error) {
+		if !sc.Next() {
+			// the iteration may have been stopped by an error, e.g. a lost connection
+			return nil, sc.Error()
+		}
+		mapper := mapperFn()
+		return mapper, ScanToMapper(sc, mapper)
+	}
+}
+
+// bufferedRows returns a rowIterator over the given rows
+func bufferedRows(rows []interface{}) rowIterator {
+	return func() (interface{}, error) {
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+// withFirstRow returns a rowIterator that returns the given first row that has been fetched from next
+// and then the remaining rows of next. If first is nil, there are no rows.
+func withFirstRow(first interface{}, next rowIterator) rowIterator {
+	if first == nil {
+		return bufferedRows(nil)
+	}
+	return func() (interface{}, error) {
+		if row := first; row != nil {
+			first = nil
+			return row, nil
+		}
+		return next()
+	}
+}
+
+// bufferRows scans all rows of the given scanner
+func bufferRows(sc Scanner, mapperFn func() interface{}) (rows []interface{}, err error) {
+	next := scanRows(sc, mapperFn)
+	for {
+		row, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			return rows, nil
+		}
+		rows = append(rows, row)
+	}
+}
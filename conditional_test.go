@@ -0,0 +1,121 @@
+package wsi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type setTime time.Time
+
+func (s setTime) Set(target interface{}) error {
+	*(target.(*time.Time)) = time.Time(s)
+	return nil
+}
+
+type article struct {
+	ID      int       `sql:"id"`
+	Title   string    `sql:"title"`
+	Updated time.Time `sql:"updated_at,lastmodified"`
+}
+
+var (
+	articleCols = []string{"id", "title", "updated_at"}
+	firstMod    = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	secondMod   = time.Date(2020, 2, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func articleRows(title string) []map[string]Setter {
+	return []map[string]Setter{
+		{"id": SetInt(1), "title": SetString(title), "updated_at": setTime(firstMod)},
+		{"id": SetInt(2), "title": SetString("second"), "updated_at": setTime(secondMod)},
+	}
+}
+
+func TestQueryConditional(t *testing.T) {
+	var calls int
+	fn := func(title string) QueryOptionsFunc {
+		return func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			calls++
+			return NewTestQuery(articleCols, articleRows(title)...), nil
+		}
+	}
+	rs := Ressource{RessourceFunc: func() interface{} { return &article{} }}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/article/", nil)
+	rs.QueryWithOptions(fn("first")).SetConditional(true).ServeHTTP(rec, req)
+
+	etag := rec.Header().Get("ETag")
+	if rec.Code != 200 || len(etag) < 3 || etag[:2] != "W/" {
+		t.Fatalf("status = %v, ETag = %#v, want 200 and a weak ETag", rec.Code, etag)
+	}
+
+	if got, want := rec.Header().Get("Last-Modified"), secondMod.Format(http.TimeFormat); got != want {
+		t.Errorf("Last-Modified = %#v, want: %#v", got, want)
+	}
+
+	tests := []struct {
+		title, header, value string
+		status               int
+	}{
+		{"first", "If-None-Match", etag, 304},
+		{"changed", "If-None-Match", etag, 200},
+		{"first", "If-Modified-Since", secondMod.Format(http.TimeFormat), 304},
+		{"first", "If-Modified-Since", firstMod.Format(http.TimeFormat), 200},
+	}
+
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/article/", nil)
+		req.Header.Set(test.header, test.value)
+		rs.QueryWithOptions(fn(test.title)).SetConditional(true).ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if test.status == 304 && rec.Body.Len() != 0 {
+			t.Errorf("[%d] body = %#v, want empty body", i, rec.Body.String())
+		}
+	}
+
+	calls = 0
+	version := func(r *http.Request) (string, error) { return "42", nil }
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/article/", nil)
+	req.Header.Set("If-None-Match", `"42"`)
+	rs.QueryWithOptions(fn("first")).SetVersion(version).ServeHTTP(rec, req)
+
+	if rec.Code != 304 || calls != 0 {
+		t.Errorf("status = %v, calls = %v, want: 304 without calling the QueryFunc", rec.Code, calls)
+	}
+}
+
+func TestGetConditional(t *testing.T) {
+	rs := Ressource{RessourceFunc: func() interface{} { return &article{} }}
+	get := rs.Get(func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery(articleCols, articleRows("first")[0]), nil
+	})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/article/1", nil)
+	get.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+
+	if got, want := rec.Header().Get("Last-Modified"), firstMod.Format(http.TimeFormat); got != want {
+		t.Errorf("Last-Modified = %#v, want: %#v", got, want)
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/article/1", nil)
+		req.Header.Set("If-None-Match", inm)
+		get.ServeHTTP(rec, req)
+
+		if rec.Code != 304 {
+			t.Errorf("If-None-Match %s => status = %v, want: %v", inm, rec.Code, 304)
+		}
+	}
+}
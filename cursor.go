@@ -0,0 +1,117 @@
+package wsi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Cursor is the position after the last row of a page for keyset pagination.
+// Values are the values of the sort columns of the last row, in the order of Sort.
+type Cursor struct {
+	Sort   []SortField
+	Values []interface{}
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type cursorPayload struct {
+	Sort   []SortField       `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// cursorOf returns the cursor for the row inside the given mapper
+func cursorOf(structPtr interface{}, sort []SortField) (c Cursor, err error) {
+	var cols columns
+	cols, err = sqlColumns(structPtr)
+	if err != nil {
+		return
+	}
+	v := reflect.ValueOf(structPtr).Elem()
+	c.Sort = sort
+	c.Values = make([]interface{}, len(sort))
+	for i, s := range sort {
+		col, ok := cols[s.Column]
+		if !ok {
+			return c, errors.New("unknown column " + s.Column)
+		}
+		c.Values[i] = v.FieldByIndex(col.Field.Index).Interface()
+	}
+	return
+}
+
+// encodeCursor returns an opaque string for the given cursor that is signed with the given secret
+func encodeCursor(secret []byte, c Cursor) (string, error) {
+	p := cursorPayload{Sort: c.Sort, Values: make([]json.RawMessage, len(c.Values))}
+	for i, v := range c.Values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		p.Values[i] = b
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(data) + "." + enc.EncodeToString(signCursor(secret, data)), nil
+}
+
+// decodeCursor decodes a cursor that has been encoded by encodeCursor with the same secret.
+// The values are converted to the types of the fields of the columns of the struct structPtr points to.
+func decodeCursor(secret []byte, s string, structPtr interface{}) (*Cursor, error) {
+	cols, err := sqlColumns(structPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := base64.RawURLEncoding
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+
+	data, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, signCursor(secret, data)) {
+		return nil, errInvalidCursor
+	}
+
+	var p cursorPayload
+	if err = json.Unmarshal(data, &p); err != nil || len(p.Sort) != len(p.Values) {
+		return nil, errInvalidCursor
+	}
+
+	c := &Cursor{Sort: p.Sort, Values: make([]interface{}, len(p.Values))}
+	for i, s := range p.Sort {
+		col, ok := cols[s.Column]
+		if !ok {
+			return nil, errInvalidCursor
+		}
+		typ := col.Field.Type
+		if typ.Kind() == reflect.Interface {
+			typ = reflect.TypeOf((*interface{})(nil)).Elem()
+		}
+		ptr := reflect.New(typ)
+		if err = json.Unmarshal(p.Values[i], ptr.Interface()); err != nil {
+			return nil, errInvalidCursor
+		}
+		c.Values[i] = ptr.Elem().Interface()
+	}
+	return c, nil
+}
+
+func signCursor(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
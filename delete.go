@@ -0,0 +1,119 @@
+package wsi
+
+import (
+	"database/sql"
+	"net/http"
+	"path"
+	"time"
+)
+
+// DeleteFunc deletes the row with the given key and returns the number of affected rows.
+// The same rules as for ExecFunc apply, but DeleteFunc must not write to the response
+// writer if no error is returned.
+type DeleteFunc func(key string, w http.ResponseWriter, r *http.Request) (rowsAffected int64, err error)
+
+// KeyFunc extracts the key of the ressource from the request. If no key is found, the empty string is returned.
+type KeyFunc func(r *http.Request) string
+
+// LastPathSegment is the default KeyFunc. It returns the last segment of the path of the request,
+// e.g. "12" for /person/12
+func LastPathSegment(r *http.Request) string {
+	p := r.URL.Path
+	if p == "" || p[len(p)-1] == '/' {
+		return ""
+	}
+	return path.Base(p)
+}
+
+// Delete is a http.Handler that deletes a row by its key
+type Delete struct {
+	mapperFn func() interface{}
+	fn       DeleteFunc
+	key      KeyFunc
+	ifMatch  *ifMatch
+	errorHandling
+}
+
+func (wd Delete) SetErrorCallback(fn func(*http.Request, error)) Delete {
+	wd.errorHandler = fn
+	return wd
+}
+
+// SetIfMatch enables optimistic concurrency, see Exec.SetIfMatch. The DeleteFunc must delete
+// conditionally with the version of the matched row (see MatchedPrecondition). If it deletes no row,
+// http.StatusPreconditionFailed is written.
+func (wd Delete) SetIfMatch(load RowLoader, required bool) Delete {
+	wd.ifMatch = &ifMatch{load: load, required: required}
+	return wd
+}
+
+// SetKey sets the KeyFunc that extracts the key from the request, see LastPathSegment
+func (wd Delete) SetKey(fn KeyFunc) Delete {
+	wd.key = fn
+	return wd
+}
+
+// SetErrorMapper sets the ErrorMapper that writes the response for errors that happen before
+// anything has been written, see DefaultErrorMapper
+func (wd Delete) SetErrorMapper(m ErrorMapper) Delete {
+	wd.errorMapper = m
+	return wd
+}
+
+// SetTimeout sets the maximal duration of a request, see Query.SetTimeout
+func (wd Delete) SetTimeout(d time.Duration) Delete {
+	wd.timeout = d
+	return wd
+}
+
+// SetUnavailable sets the function that classifies errors as unavailability of the database, see Query.SetUnavailable
+func (wd Delete) SetUnavailable(fn func(error) bool) Delete {
+	wd.unavailable = fn
+	return wd
+}
+
+// ServeHTTP calls the DeleteFunc with the key of the request. It writes http.StatusNoContent
+// if a row was deleted and http.StatusNotFound if there is no key or no row was deleted.
+func (wd Delete) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withTimeout(r, wd.timeout)
+	defer cancel()
+
+	tw := &writeTracker{ResponseWriter: w}
+	w = tw
+
+	key := wd.key(r)
+	if key == "" {
+		writeError(w, r, wd.errorMapper, sql.ErrNoRows)
+		return
+	}
+
+	var precondition bool
+	if wd.ifMatch != nil {
+		var ok bool
+		if r, ok = wd.ifMatch.check(w, r, wd.mapperFn, wd.fail); !ok {
+			return
+		}
+		_, precondition = MatchedPrecondition(r)
+	}
+
+	n, err := wd.fn(key, w, r)
+	if err != nil {
+		if !tw.written {
+			wd.fail(w, r, err)
+			return
+		}
+		wd.callback(r, err)
+		return
+	}
+
+	if n == 0 && precondition {
+		writeError(w, r, wd.errorMapper, ErrPreconditionFailed)
+		return
+	}
+
+	if n == 0 {
+		writeError(w, r, wd.errorMapper, sql.ErrNoRows)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package wsi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	errDB := errors.New("db error")
+
+	tests := []struct {
+		path   string
+		n      int64
+		err    error
+		status int
+	}{
+		{"/person/12", 1, nil, 204},
+		{"/person/12", 0, nil, 404},
+		{"/person/", 1, nil, 404},
+		{"/person/12", 0, errDB, 500},
+	}
+
+	for i, test := range tests {
+		var key string
+		fn := func(k string, w http.ResponseWriter, r *http.Request) (int64, error) {
+			key = k
+			return test.n, test.err
+		}
+
+		var err error
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", test.path, nil)
+		Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { err = e }}.ServeDelete(fn, rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if err != test.err {
+			t.Errorf("[%d] error callback got %v, want: %v", i, err, test.err)
+		}
+
+		if test.status != 404 && key != "12" {
+			t.Errorf("[%d] key = %#v, want: %#v", i, key, "12")
+		}
+	}
+}
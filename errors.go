@@ -0,0 +1,176 @@
+package wsi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-on/wsi/sqlerrors"
+)
+
+// ErrorMapper maps an error that happened before anything has been written to the response
+// to a http status code and a body that is served as json. If body is nil, only the status code is written.
+type ErrorMapper func(err error) (status int, body interface{})
+
+// StatusError is an error that knows its http status code
+type StatusError interface {
+	error
+	Status() int
+}
+
+// ValidationError are errors keyed by field, like they are returned by a Validater.
+// It may be returned by a QueryFunc or an ExecFunc and is served in the same format as
+// the errors of a Validater.
+type ValidationError map[string]error
+
+func (v ValidationError) Error() string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = k + ": " + v[k].Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (v ValidationError) MarshalJSON() ([]byte, error) {
+	return errsMarshaller(v).MarshalJSON()
+}
+
+var constraintMessages = map[sqlerrors.Kind]string{
+	sqlerrors.Unique:     "already exists",
+	sqlerrors.ForeignKey: "refers to a missing entry",
+	sqlerrors.NotNull:    "must not be null",
+	sqlerrors.Check:      "is invalid",
+}
+
+// ConstraintValidationError returns a ValidationError for the given error, if it is a constraint violation
+// (see sqlerrors.Decode). It is keyed by the column or - if the driver did not report the column - by the
+// name of the constraint. If the error is no constraint violation or neither is known, nil is returned.
+func ConstraintValidationError(err error) ValidationError {
+	ce := sqlerrors.Decode(err)
+	if ce == nil {
+		return nil
+	}
+	key := ce.Column
+	if key == "" {
+		key = ce.Constraint
+	}
+	if key == "" {
+		return nil
+	}
+	return ValidationError{key: errors.New(constraintMessages[ce.Kind])}
+}
+
+// DefaultErrorMapper is the ErrorMapper that is used by Query, Get, Exec and Delete, if none is set. It maps
+//   sql.ErrNoRows                                      => http.StatusNotFound
+//   context.DeadlineExceeded                           => http.StatusGatewayTimeout
+//   ValidationError, QueryValuesError                  => http.StatusBadRequest (with the field errors)
+//   StatusError                                        => the status of the error, e.g. for *TimeoutError
+//   unique violations                                  => http.StatusConflict
+//   other constraint violations, e.g. foreign keys     => http.StatusUnprocessableEntity
+//   everything else                                    => http.StatusInternalServerError
+// Constraint violations are recognized by sqlerrors.Decode and reported as field errors, see ConstraintValidationError.
+// The body is a Problem. The messages of other errors than field errors are not exposed.
+func DefaultErrorMapper(err error) (status int, body interface{}) {
+	var (
+		valErr   ValidationError
+		queryErr QueryValuesError
+		stErr    StatusError
+		ce       = sqlerrors.Decode(err)
+	)
+
+	switch {
+	case errors.As(err, &valErr):
+		p := NewProblem(http.StatusBadRequest, "validation failed")
+		p.Errors = errorMessages(valErr)
+		return p.Status, p
+	case errors.As(err, &queryErr):
+		p := NewProblem(http.StatusBadRequest, "invalid query values")
+		p.Errors = errorMessages(queryErr)
+		return p.Status, p
+	case errors.Is(err, sql.ErrNoRows):
+		status = http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.As(err, &stErr):
+		status = stErr.Status()
+	case ce != nil:
+		status = http.StatusUnprocessableEntity
+		if ce.Kind == sqlerrors.Unique {
+			status = http.StatusConflict
+		}
+		p := NewProblem(status, "constraint violated")
+		if errs := ConstraintValidationError(err); errs != nil {
+			p.Errors = errorMessages(errs)
+		}
+		return status, p
+	default:
+		status = http.StatusInternalServerError
+	}
+
+	return status, NewProblem(status, "")
+}
+
+// errorHandling is embedded by the handlers (Query, Exec, Get and Delete) and holds what they need to handle errors
+type errorHandling struct {
+	errorHandler func(*http.Request, error)
+	errorMapper  ErrorMapper
+	timeout      time.Duration
+	unavailable  func(error) bool
+}
+
+// fail handles an error that happened before anything has been written.
+// Timeouts and unavailability of the database become a *TimeoutError, the status code and body
+// are written by the ErrorMapper and the error is passed to the error callback.
+func (eh errorHandling) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if te := timeoutError(r, eh.timeout, eh.unavailable, err); te != nil {
+		err = te
+	}
+	writeError(w, r, eh.errorMapper, err)
+	eh.callback(r, err)
+}
+
+// callback passes the given error to the error callback, if there is one
+func (eh errorHandling) callback(r *http.Request, err error) {
+	if eh.errorHandler != nil {
+		eh.errorHandler(r, err)
+	}
+}
+
+// writeError writes the status code and the body the given mapper returns for the error.
+// A Problem is served with ServeProblem, other bodies as json.
+func writeError(w http.ResponseWriter, r *http.Request, mapper ErrorMapper, err error) {
+	if mapper == nil {
+		mapper = DefaultErrorMapper
+	}
+	status, body := mapper(err)
+	switch b := body.(type) {
+	case nil:
+		w.WriteHeader(status)
+	case Problem:
+		b.Status = status
+		ServeProblem(b, w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		ServeJSON(b, w)
+	}
+}
+
+// streamErrorMessage returns the message for an error that happened while streaming: the status text
+// of the status the mapper returns for the error, e.g. "Internal Server Error"
+func streamErrorMessage(mapper ErrorMapper, err error) string {
+	if mapper == nil {
+		mapper = DefaultErrorMapper
+	}
+	status, _ := mapper(err)
+	return http.StatusText(status)
+}
@@ -0,0 +1,131 @@
+package wsi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-on/pq"
+)
+
+type stateErr string
+
+func (s stateErr) Error() string    { return "sql error " + string(s) }
+func (s stateErr) SQLState() string { return string(s) }
+
+func TestDefaultErrorMapper(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{errors.New("x"), 500},
+		{sql.ErrNoRows, 404},
+		{fmt.Errorf("wrapped: %w", sql.ErrNoRows), 404},
+		{context.DeadlineExceeded, 504},
+		{&TimeoutError{Unavailable: true, Err: errors.New("x")}, 503},
+		{ValidationError{"Name": errors.New("missing")}, 400},
+		{QueryValuesError{"limit": errors.New("invalid")}, 400},
+		{stateErr("23505"), 409},
+		{stateErr("23503"), 422},
+		{stateErr("42P01"), 500},
+	}
+
+	for _, test := range tests {
+		if status, _ := DefaultErrorMapper(test.err); status != test.status {
+			t.Errorf("DefaultErrorMapper(%v) = %v, want: %v", test.err, status, test.status)
+		}
+	}
+}
+
+func TestExecErrorMapper(t *testing.T) {
+	tests := []struct {
+		err    error
+		mapper ErrorMapper
+		status int
+		body   string
+	}{
+		{stateErr("23505"), nil, 409, `{"title":"Conflict","status":409,"detail":"constraint violated","instance":"/"}` + "\n"},
+		{
+			pq.Error{Code: "23505", Detail: "Key (Name)=(Peter) already exists."},
+			nil,
+			409,
+			`{"title":"Conflict","status":409,"detail":"constraint violated","instance":"/","errors":{"Name":"already exists"}}` + "\n",
+		},
+		{ValidationError{"Name": errors.New("taken")}, nil, 400, `{"title":"Bad Request","status":400,"detail":"validation failed","instance":"/","errors":{"Name":"taken"}}` + "\n"},
+		{
+			errors.New("x"),
+			func(error) (int, interface{}) { return http.StatusTeapot, nil },
+			http.StatusTeapot,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			return test.err
+		}
+
+		var err error
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"Name":"Peter"}`))
+		Ressource{RessourceFunc: newPersonData, ErrorHandler: func(r *http.Request, e error) { err = e }}.Exec(fn).SetErrorMapper(test.mapper).ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%v => status = %v, want: %v", test.err, rec.Code, test.status)
+		}
+
+		if got := rec.Body.String(); got != test.body {
+			t.Errorf("%v => body = %#v, want: %#v", test.err, got, test.body)
+		}
+
+		if fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("%v => error callback got %v", test.err, err)
+		}
+	}
+}
+
+func TestRessourceErrorMapper(t *testing.T) {
+	failing := errors.New("x")
+	rs := Ressource{
+		RessourceFunc: newPersonData,
+		ErrorMapper:   func(error) (int, interface{}) { return http.StatusTeapot, nil },
+	}
+	rt := rs.Router("/person", Routes{
+		List: func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			return nil, failing
+		},
+		Create: func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			return failing
+		},
+		Get: func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			return nil, failing
+		},
+		Delete: func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+			return 0, failing
+		},
+	})
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{"GET", "/person/", ""},
+		{"POST", "/person/", `{"Name":"Peter"}`},
+		{"GET", "/person/12", ""},
+		{"DELETE", "/person/12", ""},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("%s %s => status = %v, want: %v", test.method, test.path, rec.Code, http.StatusTeapot)
+		}
+	}
+}
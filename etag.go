@@ -0,0 +1,177 @@
+package wsi
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ETag returns the strong entity tag of the given mapper. If the mapper has a column with the etag option
+// (e.g. `sql:"version,etag"`), the tag is the value of the column. Otherwise - or if the value is NULL -
+// it is a hash of the values of all columns.
+func ETag(mapper interface{}) (string, error) {
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return "", err
+	}
+	sv := reflect.Indirect(reflect.ValueOf(mapper))
+	m := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		v := sv.FieldByIndex(col.Field.Index)
+		m[col.Name] = v.Interface()
+		if !col.hasOption("etag") {
+			continue
+		}
+		if s, ok := etagValue(v); ok && !strings.ContainsAny(s, "\"\\") {
+			return `"` + s + `"`, nil
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagValue returns the string representation of the value of an etag column. Pointers and interfaces
+// (e.g. the optional types of github.com/go-on/builtin) are dereferenced, driver.Valuers like sql.NullInt64
+// are asked for their value. ok is false, if the value is NULL.
+func etagValue(v reflect.Value) (s string, ok bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	i := v.Interface()
+	if valuer, isValuer := i.(driver.Valuer); isValuer {
+		dv, err := valuer.Value()
+		if err != nil || dv == nil {
+			return "", false
+		}
+		i = dv
+	}
+	if t, isTime := i.(time.Time); isTime {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+	return fmt.Sprint(i), true
+}
+
+// matchETag reports if the given If-Match header matches the given entity tag.
+// Weak tags never match (strong comparison, RFC 7232).
+func matchETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrPreconditionFailed should be returned by an ExecFunc, if a conditional update (e.g. with
+// WHERE version = $n, see MatchedPrecondition) affected no rows. It is written as http.StatusPreconditionFailed.
+var ErrPreconditionFailed error = preconditionError{}
+
+type preconditionError struct{}
+
+func (preconditionError) Error() string { return "precondition failed" }
+func (preconditionError) Status() int   { return http.StatusPreconditionFailed }
+
+// Precondition is the state of the current row that matched the If-Match header of a request
+type Precondition struct {
+	// ETag is the entity tag of the row
+	ETag string
+
+	// Version is the value of the column with the etag option or nil, if the ETag is a hash of the columns
+	Version interface{}
+}
+
+type preconditionKey struct{}
+
+// MatchedPrecondition returns the Precondition of the request, if its If-Match header has been checked
+// (see Exec.SetIfMatch). Since the row may change between the check and the ExecFunc or DeleteFunc,
+// the update or delete must be conditional, e.g.
+//   UPDATE person SET ..., version = version + 1 WHERE id = $1 AND version = $2
+// with the Version as last parameter.
+func MatchedPrecondition(r *http.Request) (Precondition, bool) {
+	p, ok := r.Context().Value(preconditionKey{}).(Precondition)
+	return p, ok
+}
+
+// ifMatch checks the If-Match header of requests against the ETag of the current row
+type ifMatch struct {
+	load     RowLoader
+	required bool
+}
+
+// check writes http.StatusPreconditionRequired, if the If-Match header is required but missing,
+// and http.StatusPreconditionFailed, if it does not match the ETag of the current row or if there is no row.
+// Errors of the loader are passed to fail. It returns false, if something has been written.
+// Otherwise the returned request carries the matched Precondition, if the header was set.
+func (im *ifMatch) check(w http.ResponseWriter, r *http.Request, mapperFn func() interface{}, fail func(http.ResponseWriter, *http.Request, error)) (*http.Request, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if im.required {
+			ServeProblem(NewProblem(http.StatusPreconditionRequired, "If-Match header required"), w, r)
+			return r, false
+		}
+		return r, true
+	}
+
+	mapper := mapperFn()
+	err := loadRow(im.load, r, mapper)
+	if errors.Is(err, sql.ErrNoRows) {
+		ServeProblem(NewProblem(http.StatusPreconditionFailed, ""), w, r)
+		return r, false
+	}
+	if err != nil {
+		fail(w, r, err)
+		return r, false
+	}
+
+	etag, err := ETag(mapper)
+	if err != nil {
+		fail(w, r, err)
+		return r, false
+	}
+	if !matchETag(header, etag) {
+		ServeProblem(NewProblem(http.StatusPreconditionFailed, ""), w, r)
+		return r, false
+	}
+	p := Precondition{ETag: etag, Version: version(mapper)}
+	return r.WithContext(context.WithValue(r.Context(), preconditionKey{}, p)), true
+}
+
+// version returns the dereferenced value of the column with the etag option of the given mapper,
+// or nil if there is no such column or its value is NULL
+func version(mapper interface{}) interface{} {
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return nil
+	}
+	for _, col := range cols {
+		if !col.hasOption("etag") {
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(mapper)).FieldByIndex(col.Field.Index)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		return v.Interface()
+	}
+	return nil
+}
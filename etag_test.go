@@ -0,0 +1,228 @@
+package wsi
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-on/builtin"
+)
+
+type versionedBook struct {
+	ID      int    `sql:"id"`
+	Title   string `sql:"title"`
+	Version int    `sql:"version,etag"`
+}
+
+func loadVersionedBook(r *http.Request) (Scanner, error) {
+	return NewTestQuery([]string{"id", "title", "version"},
+		map[string]Setter{"id": SetInt(3), "title": SetString("Go"), "version": SetInt(7)}), nil
+}
+
+func TestETag(t *testing.T) {
+	etag, err := ETag(&versionedBook{ID: 3, Version: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag != `"7"` {
+		t.Errorf("ETag = %#v, want: %#v", etag, `"7"`)
+	}
+
+	a, _ := ETag(&book{ID: 3, Title: "Go"})
+	b, _ := ETag(&book{ID: 3, Title: "Go"})
+	c, _ := ETag(&book{ID: 3, Title: "Rust"})
+	if a != b || a == c || len(a) != 34 {
+		t.Errorf("hashed ETags %#v, %#v, %#v are not stable or not distinct", a, b, c)
+	}
+}
+
+type pointerVersionBook struct {
+	ID      int  `sql:"id"`
+	Version *int `sql:"version,etag"`
+}
+
+type nullVersionBook struct {
+	ID      int           `sql:"id"`
+	Version sql.NullInt64 `sql:"version,etag"`
+}
+
+type builtinVersionBook struct {
+	ID      int             `sql:"id"`
+	Version builtin.Int64er `sql:"version,etag"`
+}
+
+func TestETagVersionTypes(t *testing.T) {
+	v1, v2 := 7, 7
+	tests := []struct {
+		mapper interface{}
+		want   string
+	}{
+		{&pointerVersionBook{ID: 3, Version: &v1}, `"7"`},
+		{&pointerVersionBook{ID: 3, Version: &v2}, `"7"`},
+		{&nullVersionBook{ID: 3, Version: sql.NullInt64{Int64: 7, Valid: true}}, `"7"`},
+		{&builtinVersionBook{ID: 3, Version: builtin.Int64(7)}, `"7"`},
+	}
+
+	for i, test := range tests {
+		got, err := ETag(test.mapper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("[%d] ETag = %#v, want: %#v", i, got, test.want)
+		}
+	}
+
+	// NULL versions fall back to the hash of the columns
+	a, _ := ETag(&pointerVersionBook{ID: 3})
+	b, _ := ETag(&nullVersionBook{ID: 3})
+	if len(a) != 34 || len(b) != 34 {
+		t.Errorf("ETags of NULL versions = %#v, %#v, want hashes", a, b)
+	}
+}
+
+func TestGetETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/book/3", nil)
+	Ressource{RessourceFunc: func() interface{} { return &versionedBook{} }}.ServeGet(func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return loadVersionedBook(r)
+	}, rec, req)
+
+	if got := rec.Header().Get("ETag"); got != `"7"` {
+		t.Errorf("ETag = %#v, want: %#v", got, `"7"`)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		method, ifMatch string
+		required        bool
+		status          int
+	}{
+		{"PUT", `"7"`, true, 200},
+		{"PUT", `"6", "7"`, true, 200},
+		{"PUT", `*`, true, 200},
+		{"PUT", `W/"7"`, true, 412},
+		{"PUT", `"6"`, true, 412},
+		{"PUT", "", true, 428},
+		{"PUT", "", false, 200},
+		{"POST", "", true, 200},
+		{"DELETE", `"6"`, true, 412},
+		{"DELETE", `"7"`, true, 204},
+		{"DELETE", "", true, 428},
+	}
+
+	rs := Ressource{RessourceFunc: func() interface{} { return &versionedBook{} }}
+	for i, test := range tests {
+		var called bool
+		var h http.Handler
+		if test.method == "DELETE" {
+			h = rs.Delete(func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+				called = true
+				return 1, nil
+			}).SetIfMatch(loadVersionedBook, test.required)
+		} else {
+			h = rs.Exec(func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+				called = true
+				return nil
+			}).SetIfMatch(loadVersionedBook, test.required)
+		}
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/book/3", strings.NewReader(`{"Title":"Go"}`))
+		if test.ifMatch != "" {
+			req.Header.Set("If-Match", test.ifMatch)
+		}
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if want := test.status < 400; called != want {
+			t.Errorf("[%d] called = %v, want: %v", i, called, want)
+		}
+	}
+}
+
+func TestIfMatchConditionalUpdate(t *testing.T) {
+	rs := Ressource{RessourceFunc: func() interface{} { return &versionedBook{} }}
+
+	// another request changed the row between the check and the update
+	var p Precondition
+	exec := rs.Exec(func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+		p, _ = MatchedPrecondition(r)
+		return ErrPreconditionFailed
+	}).SetIfMatch(loadVersionedBook, true)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/book/3", strings.NewReader(`{"Title":"Go"}`))
+	req.Header.Set("If-Match", `"7"`)
+	exec.ServeHTTP(rec, req)
+
+	if rec.Code != 412 {
+		t.Errorf("PUT status = %v, want: %v", rec.Code, 412)
+	}
+
+	if p.ETag != `"7"` || p.Version != 7 {
+		t.Errorf("precondition = %#v, want ETag \"7\" and Version 7", p)
+	}
+
+	del := rs.Delete(func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+		return 0, nil
+	}).SetIfMatch(loadVersionedBook, true)
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/book/3", nil)
+	req.Header.Set("If-Match", `"7"`)
+	del.ServeHTTP(rec, req)
+
+	if rec.Code != 412 {
+		t.Errorf("DELETE status = %v, want: %v", rec.Code, 412)
+	}
+}
+
+func TestGetVersionIfMatch(t *testing.T) {
+	var called bool
+	rs := Ressource{RessourceFunc: func() interface{} { return &versionedBook{} }}
+	get := rs.Get(func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		called = true
+		return loadVersionedBook(r)
+	}).SetVersion(func(r *http.Request) (string, error) { return "7", nil })
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/book/3", nil)
+	get.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+
+	if etag != `"7"` {
+		t.Errorf("ETag = %#v, want: %#v", etag, `"7"`)
+	}
+
+	called = false
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/book/3", nil)
+	req.Header.Set("If-None-Match", etag)
+	get.ServeHTTP(rec, req)
+
+	if rec.Code != 304 || called {
+		t.Errorf("If-None-Match => status = %v, called = %v, want: 304, false", rec.Code, called)
+	}
+
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Errorf("If-None-Match => ETag = %#v, want: %#v", got, etag)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/book/3", strings.NewReader(`{"Title":"Go"}`))
+	req.Header.Set("If-Match", etag)
+	rs.Exec(func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}).SetIfMatch(loadVersionedBook, true).ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("If-Match with the ETag of Get => status = %v, want: %v", rec.Code, 200)
+	}
+}
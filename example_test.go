@@ -31,18 +31,13 @@ func findPersonsFake(limit, offset int, w http.ResponseWriter, r *http.Request)
 // creates a http.Handler based on findPersonsFake that writes the resulting persons as json
 // we are using the fake query here to avoid the need for a database, you may replace findPersonsFake
 // with findPersons if you have a real database connection
-var findHandler = wsi.Ressource{newPerson, printErr}.Query(findPersonsFake)
+var findHandler = wsi.Ressource{RessourceFunc: newPerson, ErrorHandler: printErr}.Query(findPersonsFake)
 
 var DB *sql.DB
 
 // findPersons defines the search sql.
-// it must handle edge case, like limit = 0 or max limits, however limit and offset will never be < 0
+// limit and offset will never be < 0 and the limit is ensured by the Limits of the handler, see listHandler
 func findPersons(limit, offset int, w http.ResponseWriter, r *http.Request) (wsi.Scanner, error) {
-	// handle max limit
-	if limit == 0 || limit > 30 {
-		limit = 30
-	}
-
 	return wsi.DBQuery(
 		DB,
 		`SELECT "ID","Name" from person ORDER BY "ID" LIMIT $1 OFFSET $2`,
@@ -51,6 +46,9 @@ func findPersons(limit, offset int, w http.ResponseWriter, r *http.Request) (wsi
 	)
 }
 
+// creates a http.Handler based on findPersons that returns 30 persons by default and at most 30 persons
+var listHandler = wsi.Ressource{RessourceFunc: newPerson, ErrorHandler: printErr}.Query(findPersons).SetLimits(wsi.Limits{Default: 30, Max: 30})
+
 // createPerson creates a person based on the values of the given ColumnsMapper
 // and writes to the given responsewriter
 // we need to return an error here, even if we handle the response writing, so that the general
@@ -65,7 +63,7 @@ func createPerson(m map[string]interface{}, w http.ResponseWriter, r *http.Reque
 }
 
 // creates a http.Handler based on createPerson that load persons as json
-var addHandler = wsi.Ressource{newPerson, printErr}.Exec(createPerson)
+var addHandler = wsi.Ressource{RessourceFunc: newPerson, ErrorHandler: printErr}.Exec(createPerson)
 
 func Example() {
 	rec := httptest.NewRecorder()
@@ -4,117 +4,183 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
+	"time"
 )
 
 // Exec is a http.Handler that execs a ExecFunc
 type Exec struct {
-	mapperFn     func() interface{}
-	fn           ExecFunc
-	errorHandler func(*http.Request, error)
-	dec          RequestDecoder
+	mapperFn func() interface{}
+	fn       ExecFunc
+	dec      RequestDecoder
+	ifMatch  *ifMatch
+	errorHandling
 }
 
 func (we Exec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withTimeout(r, we.timeout)
+	defer cancel()
+
+	tw := &writeTracker{ResponseWriter: w}
+	w = tw
+
+	if we.ifMatch != nil && (r.Method == "PUT" || r.Method == "PATCH" || r.Method == "DELETE") {
+		var ok bool
+		if r, ok = we.ifMatch.check(w, r, we.mapperFn, we.fail); !ok {
+			return
+		}
+	}
+
 	mapper := we.mapperFn()
 	var err error
 	if r.Body == nil {
 		err = errors.New("empty body")
-		w.WriteHeader(http.StatusBadRequest)
-		if we.errorHandler != nil {
-			we.errorHandler(r, err)
-		}
+		ServeProblem(NewProblem(http.StatusBadRequest, err.Error()), w, r)
+		we.callback(r, err)
 		return
 	}
 	defer r.Body.Close()
-	err = we.dec.Decode(r, mapper)
+	var present map[string]bool
+	if pd, ok := we.dec.(PatchDecoder); ok && r.Method == "PATCH" {
+		present, err = pd.DecodePatch(r, mapper)
+	} else {
+		err = we.dec.Decode(r, mapper)
+	}
+	var (
+		loadErr *LoadError
+		stErr   StatusError
+	)
+	if errors.As(err, &loadErr) || errors.As(err, &stErr) {
+		we.fail(w, r, err)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		if we.errorHandler != nil {
-			we.errorHandler(r, err)
-		}
+		ServeProblem(NewProblem(http.StatusBadRequest, "invalid body"), w, r)
+		we.callback(r, err)
 		return
 	}
 
-	switch r.Method {
-	case "PUT":
-		if val, ok := mapper.(PUTValidater); ok {
-			errs := val.ValidatePUT()
-			if len(errs) > 0 {
-				w.WriteHeader(http.StatusBadRequest)
-				ServeJSON(errsMarshaller(errs), w)
-				return
-			}
-		} else {
-			if val, ok := mapper.(Validater); ok {
-				errs := val.Validate()
-				if len(errs) > 0 {
-					w.WriteHeader(http.StatusBadRequest)
-					ServeJSON(errsMarshaller(errs), w)
-					return
-				}
-			}
-		}
-	case "PATCH":
-		if val, ok := mapper.(PATCHValidater); ok {
-			errs := val.ValidatePATCH()
-			if len(errs) > 0 {
-				w.WriteHeader(http.StatusBadRequest)
-				ServeJSON(errsMarshaller(errs), w)
-				return
-			}
-		} else {
-			if val, ok := mapper.(Validater); ok {
-				errs := val.Validate()
-				if len(errs) > 0 {
-					w.WriteHeader(http.StatusBadRequest)
-					ServeJSON(errsMarshaller(errs), w)
-					return
-				}
-			}
-		}
-	case "POST":
-		if val, ok := mapper.(POSTValidater); ok {
-			errs := val.ValidatePOST()
-			if len(errs) > 0 {
-				w.WriteHeader(http.StatusBadRequest)
-				ServeJSON(errsMarshaller(errs), w)
-				return
-			}
-		} else {
-			if val, ok := mapper.(Validater); ok {
-				errs := val.Validate()
-				if len(errs) > 0 {
-					w.WriteHeader(http.StatusBadRequest)
-					ServeJSON(errsMarshaller(errs), w)
-					return
-				}
-			}
-		}
+	if errs := validate(r.Method, mapper); len(errs) > 0 {
+		p := NewProblem(http.StatusBadRequest, "validation failed")
+		p.Errors = errorMessages(errs)
+		ServeProblem(p, w, r)
+		return
 	}
 
 	var m map[string]interface{}
 	m, err = MapSQL(mapper)
+	if err == nil && present != nil {
+		m, err = patchMap(mapper, present)
+	}
 	if err != nil {
-		if we.errorHandler != nil {
-			we.errorHandler(r, err)
-		}
-		w.WriteHeader(http.StatusInternalServerError)
+		we.fail(w, r, err)
+		return
+	}
+
+	err = injectPathParams(r, mapper, m)
+	if err != nil {
+		we.fail(w, r, err)
 		return
 	}
 
 	err = we.fn(m, w, r)
 	if err != nil {
-		if we.errorHandler != nil {
-			we.errorHandler(r, err)
+		if !tw.written {
+			we.fail(w, r, err)
+			return
 		}
+		we.callback(r, err)
 	}
 }
 
+// patchMap returns the map of the sql columns of the given present fields of the mapper.
+// Fields that should be cleared are nil inside the map.
+func patchMap(mapper interface{}, present map[string]bool) (map[string]interface{}, error) {
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.Indirect(reflect.ValueOf(mapper))
+	m := map[string]interface{}{}
+	for field, clear := range present {
+		col, ok := cols.byField(field)
+		if !ok {
+			continue
+		}
+		if clear {
+			m[col.Name] = nil
+			continue
+		}
+		m[col.Name] = v.FieldByIndex(col.Field.Index).Interface()
+	}
+	return m, nil
+}
+
+// validate validates the given mapper for the given method with the most specific validater it implements
+func validate(method string, mapper interface{}) map[string]error {
+	switch method {
+	case "PUT":
+		if val, ok := mapper.(PUTValidater); ok {
+			return val.ValidatePUT()
+		}
+	case "PATCH":
+		if val, ok := mapper.(PATCHValidater); ok {
+			return val.ValidatePATCH()
+		}
+	case "POST":
+		if val, ok := mapper.(POSTValidater); ok {
+			return val.ValidatePOST()
+		}
+	default:
+		return nil
+	}
+
+	if val, ok := mapper.(Validater); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
 func (we Exec) SetDecoder(d RequestDecoder) Exec {
 	we.dec = d
 	return we
 }
 
+// SetTimeout sets the maximal duration of a request. The context of the request that is passed to the
+// ExecFunc is canceled after the timeout. If the ExecFunc returns an error after the timeout has been exceeded
+// and nothing has been written, http.StatusGatewayTimeout is written and a *TimeoutError is passed to the
+// error callback. A timeout of 0 means no timeout.
+func (we Exec) SetTimeout(d time.Duration) Exec {
+	we.timeout = d
+	return we
+}
+
+// SetUnavailable sets the function that classifies errors as unavailability of the database, e.g. an exhausted
+// connection pool. If the ExecFunc returns such an error and nothing has been written,
+// http.StatusServiceUnavailable is written and a *TimeoutError is passed to the error callback.
+func (we Exec) SetUnavailable(fn func(error) bool) Exec {
+	we.unavailable = fn
+	return we
+}
+
+// SetErrorMapper sets the ErrorMapper that writes the response for errors that happen before
+// anything has been written, see DefaultErrorMapper. So the ExecFunc may simply return an error.
+func (we Exec) SetErrorMapper(m ErrorMapper) Exec {
+	we.errorMapper = m
+	return we
+}
+
+// SetIfMatch enables optimistic concurrency for PUT, PATCH and DELETE requests: the If-Match header
+// is compared to the ETag of the current row that is loaded by the given loader, before the body is decoded.
+// If it does not match, http.StatusPreconditionFailed is written. If required is true and the header is missing,
+// http.StatusPreconditionRequired is written.
+// The check alone does not prevent lost updates: the ExecFunc must update conditionally with the version of
+// the matched row (see MatchedPrecondition) and return ErrPreconditionFailed, if no row was affected.
+func (we Exec) SetIfMatch(load RowLoader, required bool) Exec {
+	we.ifMatch = &ifMatch{load: load, required: required}
+	return we
+}
+
 func (we Exec) SetErrorCallback(fn func(*http.Request, error)) Exec {
 	we.errorHandler = fn
 	return we
@@ -0,0 +1,173 @@
+package wsi
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-on/builtin"
+)
+
+// reservedQueryKeys are the url query keys that are never treated as filters
+var reservedQueryKeys = map[string]bool{
+	"limit":  true,
+	"offset": true,
+	"sort":   true,
+	"fields": true,
+	"cursor": true,
+}
+
+// operators are the operators that are allowed inside the url query keys of filters
+var operators = map[Operator]bool{
+	OpEq:     true,
+	OpNe:     true,
+	OpLt:     true,
+	OpLte:    true,
+	OpGt:     true,
+	OpGte:    true,
+	OpIn:     true,
+	OpLike:   true,
+	OpIsNull: true,
+}
+
+// scanFilters scans the filters out of the given url query values. A filter has the form
+// col=value or col__op=value where col is a column of cols and op is one of eq, ne, lt, lte, gt, gte, in, like, isnull.
+// The value is converted to the type of the field of the column. For "in" the value is a comma separated list,
+// for "isnull" it must be a bool. Reserved keys and the given allowed keys are ignored, other keys that are no column
+// are reported as unknown column.
+// Errors are added to errs keyed by the url query key.
+func scanFilters(values url.Values, cols columns, allowed map[string]bool, errs QueryValuesError) (filters []Filter) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// keep the order of the filters stable
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedQueryKeys[key] || allowed[key] {
+			continue
+		}
+
+		name, op := key, OpEq
+		if i := strings.LastIndex(key, "__"); i > 0 && !cols.has(key) {
+			name, op = key[:i], Operator(key[i+2:])
+		}
+
+		col, isCol := cols[name]
+
+		if !isCol {
+			errs[key] = fmt.Errorf("unknown column %#v", name)
+			continue
+		}
+
+		if !operators[op] {
+			errs[key] = fmt.Errorf("unknown operator %#v", string(op))
+			continue
+		}
+
+		for _, v := range values[key] {
+			val, err := filterValue(col.Field.Type, op, v)
+			if err != nil {
+				errs[key] = err
+				break
+			}
+			filters = append(filters, Filter{Column: name, Operator: op, Value: val})
+		}
+	}
+	return
+}
+
+func filterValue(typ reflect.Type, op Operator, s string) (interface{}, error) {
+	switch op {
+	case OpIsNull:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no bool", s)
+		}
+		return b, nil
+	case OpLike:
+		return s, nil
+	case OpIn:
+		parts := strings.Split(s, ",")
+		vals := make([]interface{}, len(parts))
+		for i, p := range parts {
+			v, err := convertString(typ, p)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	default:
+		return convertString(typ, s)
+	}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	stringerType  = reflect.TypeOf((*builtin.Stringer)(nil)).Elem()
+	boolerType    = reflect.TypeOf((*builtin.Booler)(nil)).Elem()
+	int64erType   = reflect.TypeOf((*builtin.Int64er)(nil)).Elem()
+	float64erType = reflect.TypeOf((*builtin.Float64er)(nil)).Elem()
+)
+
+// convertString converts the given string to a value of the given type.
+// Pointer types are converted to their element type, the optional types of
+// github.com/go-on/builtin that are supported by sqlnull are converted to their basic type.
+func convertString(typ reflect.Type, s string) (interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no time in RFC3339 format", s)
+		}
+		return t, nil
+	case stringerType:
+		return s, nil
+	case boolerType:
+		return convertString(reflect.TypeOf(true), s)
+	case int64erType:
+		return convertString(reflect.TypeOf(int64(0)), s)
+	case float64erType:
+		return convertString(reflect.TypeOf(float64(0)), s)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(s).Convert(typ).Interface(), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no bool", s)
+		}
+		return reflect.ValueOf(b).Convert(typ).Interface(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no %s", s, typ)
+		}
+		return reflect.ValueOf(i).Convert(typ).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no %s", s, typ)
+		}
+		return reflect.ValueOf(i).Convert(typ).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%#v is no %s", s, typ)
+		}
+		return reflect.ValueOf(f).Convert(typ).Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
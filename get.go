@@ -0,0 +1,133 @@
+package wsi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GetFunc makes the sql query for a single row and returns a Scanner.
+// The same rules as for QueryFunc apply.
+type GetFunc func(w http.ResponseWriter, r *http.Request) (Scanner, error)
+
+// ErrTooManyRows is passed to the error callback, if the Scanner of a GetFunc returns more than one row
+var ErrTooManyRows = errors.New("more than one row")
+
+// Get is a http.Handler that serves a single row as json object
+type Get struct {
+	mapperFn func() interface{}
+	fn       GetFunc
+	version  VersionFunc
+	errorHandling
+}
+
+func (wg Get) SetErrorCallback(fn func(*http.Request, error)) Get {
+	wg.errorHandler = fn
+	return wg
+}
+
+// SetErrorMapper sets the ErrorMapper that writes the response for errors that happen before
+// anything has been written, see DefaultErrorMapper
+func (wg Get) SetErrorMapper(m ErrorMapper) Get {
+	wg.errorMapper = m
+	return wg
+}
+
+// SetTimeout sets the maximal duration of a request, see Query.SetTimeout
+func (wg Get) SetTimeout(d time.Duration) Get {
+	wg.timeout = d
+	return wg
+}
+
+// SetUnavailable sets the function that classifies errors as unavailability of the database, see Query.SetUnavailable
+func (wg Get) SetUnavailable(fn func(error) bool) Get {
+	wg.unavailable = fn
+	return wg
+}
+
+// SetVersion sets the function that returns the version of the row, see Query.SetVersion.
+// The version is only used to answer requests with a matching If-None-Match header without running the GetFunc.
+// The strong ETag of the row (see ETag) is still sent, so that it can be used with If-Match (see Exec.SetIfMatch).
+// Therefore the version should be the value of the column with the etag option.
+func (wg Get) SetVersion(fn VersionFunc) Get {
+	wg.version = fn
+	return wg
+}
+
+// ServeHTTP serves the row as json object with its ETag and Last-Modified (see Query.SetConditional).
+// Requests with matching If-None-Match or If-Modified-Since headers are answered with http.StatusNotModified.
+// If there is no row, http.StatusNotFound is written. If there is more than one row,
+// http.StatusInternalServerError is written and ErrTooManyRows is passed to the error callback.
+func (wg Get) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, cancel := withTimeout(r, wg.timeout)
+	defer cancel()
+
+	tw := &writeTracker{ResponseWriter: w}
+	w = tw
+
+	if wg.version != nil {
+		version, err := wg.version(r)
+		if err != nil {
+			wg.fail(w, r, err)
+			return
+		}
+		// the version is the value of the etag column, so its strong form is the ETag of the row
+		if etag := strings.TrimPrefix(weakETag(version), "W/"); notModified(r, etag, time.Time{}) {
+			setValidators(w, etag, time.Time{})
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	scanner, err := wg.fn(w, r)
+	if err != nil {
+		if !tw.written {
+			wg.fail(w, r, err)
+			return
+		}
+		wg.callback(r, err)
+		return
+	}
+	defer scanner.Close()
+
+	if !scanner.Next() {
+		if err = scanner.Error(); err != nil {
+			wg.fail(w, r, err)
+			return
+		}
+		writeError(w, r, wg.errorMapper, sql.ErrNoRows)
+		return
+	}
+
+	mapper := wg.mapperFn()
+	err = ScanToMapper(scanner, mapper)
+	if err != nil {
+		wg.fail(w, r, err)
+		return
+	}
+
+	if scanner.Next() {
+		wg.fail(w, r, ErrTooManyRows)
+		return
+	}
+
+	if err = scanner.Error(); err != nil {
+		wg.fail(w, r, err)
+		return
+	}
+
+	etag, _ := ETag(mapper)
+	lastMod, _ := lastModified(mapper)
+	setValidators(w, etag, lastMod)
+	if notModified(r, etag, lastMod) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err = ServeJSON(mapper, w)
+	if err != nil {
+		wg.callback(r, err)
+	}
+}
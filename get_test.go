@@ -0,0 +1,58 @@
+package wsi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// emptyScanner is a Scanner without rows
+type emptyScanner struct{}
+
+func (emptyScanner) Next() bool                { return false }
+func (emptyScanner) Scan(...interface{}) error { return nil }
+func (emptyScanner) Columns() []string         { return nil }
+func (emptyScanner) Error() error              { return nil }
+func (emptyScanner) Close() error              { return nil }
+
+func TestGet(t *testing.T) {
+	adrian := map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian")}
+	george := map[string]Setter{"Id": SetInt(24), "Name": SetString("George")}
+
+	tests := []struct {
+		rows   []map[string]Setter
+		status int
+		body   string
+		err    error
+	}{
+		{[]map[string]Setter{adrian}, 200, `{"Id":12,"Name":"Adrian"}` + "\n", nil},
+		{nil, 404, `{"title":"Not Found","status":404,"instance":"/person/12"}` + "\n", nil},
+		{[]map[string]Setter{adrian, george}, 500, `{"title":"Internal Server Error","status":500,"instance":"/person/12"}` + "\n", ErrTooManyRows},
+	}
+
+	for i, test := range tests {
+		fn := func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			if len(test.rows) == 0 {
+				return emptyScanner{}, nil
+			}
+			return NewTestQuery([]string{"Id", "Name"}, test.rows...), nil
+		}
+
+		var err error
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/person/12", nil)
+		Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { err = e }}.ServeGet(fn, rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if got := rec.Body.String(); got != test.body {
+			t.Errorf("[%d] body = %#v, want: %#v", i, got, test.body)
+		}
+
+		if err != test.err {
+			t.Errorf("[%d] error callback got %v, want: %v", i, err, test.err)
+		}
+	}
+}
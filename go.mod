@@ -1,5 +1,7 @@
 module github.com/go-on/wsi
 
+go 1.13
+
 require (
 	github.com/go-on/builtin v1.4.3
 	github.com/go-on/lib v3.2.13+incompatible
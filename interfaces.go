@@ -49,8 +49,25 @@ type StreamEncoder interface {
 	Finish()
 }
 
+// Aborter may be implemented by a StreamEncoder to handle errors that happen after the encoding started.
+// If an error happens, Abort is called instead of Finish, so that the client can tell a
+// failed response from a complete one.
+type Aborter interface {
+	Abort(error)
+}
+
 type RequestDecoder interface {
 	// decodes the given http request to the given interface.
 	// must not close the request body
 	Decode(*http.Request, interface{}) error
 }
+
+// PatchDecoder may be implemented by a RequestDecoder to decode PATCH requests, so that only
+// the fields that are present in the body are passed to the ExecFunc.
+type PatchDecoder interface {
+	RequestDecoder
+
+	// DecodePatch decodes like Decode and returns the names of the struct fields that are present
+	// in the body. The value is true, if the field should be cleared (set to NULL).
+	DecodePatch(*http.Request, interface{}) (present map[string]bool, err error)
+}
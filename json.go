@@ -3,8 +3,10 @@ package wsi
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 
 	"github.com/go-on/lib/misc/meta"
 )
@@ -64,6 +66,100 @@ func ColumnPtrs(structPtr interface{}, fields []string) ([]interface{}, error) {
 	return s.ToPtrSlice("sql", fields), nil
 }
 
+// jsonFields is a json object of some fields of a struct that keeps the order of the fields
+type jsonFields []jsonField
+
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+func (j jsonFields) MarshalJSON() ([]byte, error) {
+	var bf bytes.Buffer
+	bf.WriteString("{")
+	for i, f := range j {
+		if i > 0 {
+			bf.WriteString(",")
+		}
+		key, _ := json.Marshal(f.key)
+		bf.Write(key)
+		bf.WriteString(":")
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		bf.Write(val)
+	}
+	bf.WriteString("}")
+	return bf.Bytes(), nil
+}
+
+// selectJSONFields returns the json representation of the fields of the struct structPtr points to
+// that belong to the given sql columns. The json tags of the fields are respected.
+func selectJSONFields(structPtr interface{}, fields []string) (jsonFields, error) {
+	cols, err := sqlColumns(structPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := columns{}
+	for _, f := range fields {
+		if c, ok := cols[f]; ok {
+			selected[f] = c
+		}
+	}
+
+	v := reflect.ValueOf(structPtr).Elem()
+	var res jsonFields
+
+	for _, c := range selected.ordered() {
+		key, omitempty := jsonKey(c.Field)
+		if key == "-" {
+			continue
+		}
+		fv := v.FieldByIndex(c.Field.Index)
+		if omitempty && isEmptyValue(fv) {
+			continue
+		}
+		res = append(res, jsonField{key, fv.Interface()})
+	}
+	return res, nil
+}
+
+// jsonKey returns the key of the given field inside a json object
+func jsonKey(field reflect.StructField) (key string, omitempty bool) {
+	tvs := strings.Split(field.Tag.Get("json"), ",")
+	key = tvs[0]
+	if key == "" {
+		key = field.Name
+	}
+	for _, opt := range tvs[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return
+}
+
+// isEmptyValue reports if the value is empty in the sense of the omitempty option of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
 // NewJSONStreamer returns a JSONStreamer for the given ResponseWriter and starts writing to it.
 // The json content type is set and the opening bracket of the json array is written.
 // The next step should be to call the Encode method for every json object that should be written
@@ -90,6 +186,46 @@ func (j *JSONStreamer) Finish() {
 	j.w.Write([]byte("]"))
 }
 
+// Abort is called instead of Finish if an error happened while streaming.
+// It deliberately leaves the json array unterminated, so that the client
+// gets invalid json instead of a valid looking but truncated array.
+func (j *JSONStreamer) Abort(err error) {}
+
+// JSONEnvelope streams a json object with a data array of the rows and the meta data of the query
+// to an http.ResponseWriter:
+//   {"data":[...],"meta":{"limit":..,"offset":..,"count":..,"next":..}}
+type JSONEnvelope struct {
+	*JSONStreamer
+	meta QueryMeta
+}
+
+// NewJSONEnvelope returns a JSONEnvelope for the given ResponseWriter and starts writing to it.
+// It may be used instead of NewJSONStreamer, see Query.SetEncoder.
+func NewJSONEnvelope(w http.ResponseWriter) (StreamEncoder, error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(`{"data":[`))
+	return &JSONEnvelope{JSONStreamer: &JSONStreamer{w, json.NewEncoder(w), true}}, nil
+}
+
+// Abort is called instead of Finish if an error happened while streaming.
+// The meta data, that contains the errors, is written as with Finish.
+func (j *JSONEnvelope) Abort(err error) {
+	j.Finish()
+}
+
+// SetMeta sets the meta data that is written by Finish
+func (j *JSONEnvelope) SetMeta(m QueryMeta) {
+	j.meta = m
+}
+
+// Finish closes the data array and writes the meta data.
+// Don't write to the underlying ResponseWriter after Finish has been run.
+func (j *JSONEnvelope) Finish() {
+	j.w.Write([]byte(`],"meta":`))
+	j.enc.Encode(j.meta)
+	j.w.Write([]byte("}"))
+}
+
 func ServeJSON(i interface{}, w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	return json.NewEncoder(w).Encode(i)
@@ -101,4 +237,52 @@ func (j jsonDecoder) Decode(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
+// DecodePatch decodes the body like Decode and returns the fields whose keys are present in the json object.
+// Following JSON Merge Patch (RFC 7396) a key with the value null clears the field.
+func (j jsonDecoder) DecodePatch(r *http.Request, v interface{}) (map[string]bool, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]json.RawMessage
+	err = json.Unmarshal(body, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return presentFields(reflect.TypeOf(v), keys), nil
+}
+
+// presentFields returns the names of the fields of the struct type (or pointer to it) whose json keys are
+// inside the given object, matched like encoding/json does. The value is true, if the key is null.
+func presentFields(t reflect.Type, keys map[string]json.RawMessage) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	present := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("json") == "-" {
+			continue
+		}
+		key, _ := jsonKey(f)
+		raw, ok := keys[key]
+		if !ok {
+			for k, v := range keys {
+				if strings.EqualFold(k, key) {
+					raw, ok = v, true
+					break
+				}
+			}
+		}
+		if ok {
+			present[f.Name] = bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		}
+	}
+	return present
+}
+
 var JSONDecoder RequestDecoder = jsonDecoder{}
@@ -1,6 +1,10 @@
 package wsi
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -19,7 +23,7 @@ func TestMapViaJSON(t *testing.T) {
 	m, err := MapViaJSON(&x)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err.Error())
 	}
 
 	if m["a"] != "a" {
@@ -42,3 +46,35 @@ func TestMapViaJSON(t *testing.T) {
 		t.Errorf("%#v should have length of 2", m)
 	}
 }
+
+func TestJSONEnvelope(t *testing.T) {
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery(
+			[]string{"Id", "Name"},
+			map[string]Setter{"Id": SetInt(1), "Name": SetString("Adrian")},
+			map[string]Setter{"Id": SetInt(2), "Name": SetString("George")},
+		), nil
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/person/?limit=2", nil)
+	Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetEncoder(NewJSONEnvelope).ServeHTTP(rec, req)
+
+	var res struct {
+		Data []person
+		Meta QueryMeta
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid json %#v: %s", rec.Body.String(), err)
+	}
+
+	if len(res.Data) != 2 || res.Data[1].Name != "George" {
+		t.Errorf("data = %#v, want persons Adrian and George", res.Data)
+	}
+
+	want := QueryMeta{Limit: 2, Count: 2, Next: "/person/?limit=2&offset=2"}
+	if !reflect.DeepEqual(res.Meta, want) {
+		t.Errorf("meta = %#v, want: %#v", res.Meta, want)
+	}
+}
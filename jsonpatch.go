@@ -0,0 +1,313 @@
+package wsi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RowLoader loads the current row of the ressource that is addressed by the request, e.g. by the
+// parameters of the path (see PathParams). It must not write to the response.
+type RowLoader func(r *http.Request) (Scanner, error)
+
+// LoadError is returned by the JSONPatchDecoder, if the current row could not be loaded.
+// If there is no row, Err is sql.ErrNoRows.
+type LoadError struct {
+	Err error
+}
+
+func (l *LoadError) Error() string {
+	return "can't load row: " + l.Err.Error()
+}
+
+func (l *LoadError) Unwrap() error {
+	return l.Err
+}
+
+// PatchTestError is returned by the JSONPatchDecoder, if a test operation failed
+type PatchTestError struct {
+	Path string
+}
+
+func (p *PatchTestError) Error() string {
+	return "test failed for path " + p.Path
+}
+
+// Status returns http.StatusConflict
+func (p *PatchTestError) Status() int {
+	return http.StatusConflict
+}
+
+// JSONPatchDecoder is a PatchDecoder for JSON Patch (RFC 6902) requests with the content type
+// application/json-patch+json. The current row is loaded into the mapper, the operations add, remove,
+// replace and test are applied to its json representation and the changed fields are reported as present,
+// so that only the changed columns are passed to the ExecFunc. Removed fields are cleared.
+// Requests with other content types are decoded by JSONDecoder.
+type JSONPatchDecoder struct {
+	Load RowLoader
+}
+
+// NewJSONPatchDecoder returns a JSONPatchDecoder for the given loader
+func NewJSONPatchDecoder(load RowLoader) JSONPatchDecoder {
+	return JSONPatchDecoder{Load: load}
+}
+
+func isJSONPatch(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == "application/json-patch+json"
+}
+
+func (j JSONPatchDecoder) Decode(r *http.Request, v interface{}) error {
+	if !isJSONPatch(r) {
+		return JSONDecoder.Decode(r, v)
+	}
+	_, err := j.DecodePatch(r, v)
+	return err
+}
+
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func (j JSONPatchDecoder) DecodePatch(r *http.Request, v interface{}) (map[string]bool, error) {
+	if !isJSONPatch(r) {
+		return jsonDecoder{}.DecodePatch(r, v)
+	}
+
+	var ops []patchOperation
+	err := json.NewDecoder(r.Body).Decode(&ops)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadRow(j.Load, r, v)
+	if err != nil {
+		return nil, err
+	}
+
+	orig, err := jsonObject(v)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := jsonObject(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched interface{} = doc
+	for _, op := range ops {
+		patched, err = applyPatchOperation(patched, op)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc, ok := patched.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patched document is no json object")
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := map[string]json.RawMessage{}
+	for k, val := range orig {
+		nv, has := doc[k]
+		if !has {
+			changed[k] = json.RawMessage("null")
+			continue
+		}
+		if !reflect.DeepEqual(val, nv) {
+			changed[k], _ = json.Marshal(nv)
+		}
+	}
+	for k, nv := range doc {
+		if _, has := orig[k]; !has {
+			changed[k], _ = json.Marshal(nv)
+		}
+	}
+	return presentFields(reflect.TypeOf(v), changed), nil
+}
+
+// loadRow scans the current row the loader returns for the request into v.
+// All errors are returned as *LoadError.
+func loadRow(load RowLoader, r *http.Request, v interface{}) error {
+	sc, err := load(r)
+	if err != nil {
+		return &LoadError{err}
+	}
+	defer sc.Close()
+	if !sc.Next() {
+		err = sc.Error()
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return &LoadError{err}
+	}
+	err = ScanToMapper(sc, v)
+	if err != nil {
+		return &LoadError{err}
+	}
+	return nil
+}
+
+// jsonObject returns the json representation of the struct v points to as generic json object.
+// The omitempty option is ignored, so that every field that is visible to json can be patched.
+func jsonObject(v interface{}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	return m, addJSONFields(m, reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// addJSONFields adds the json visible fields of the given struct to m. Fields of embedded structs
+// without json name are added as if they were fields of the struct.
+func addJSONFields(m map[string]interface{}, sv reflect.Value) error {
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if err := addJSONFields(m, sv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		key, _ := jsonKey(field)
+		if key == "-" {
+			continue
+		}
+		b, err := json.Marshal(sv.Field(i).Interface())
+		if err != nil {
+			return err
+		}
+		var val interface{}
+		if err = json.Unmarshal(b, &val); err != nil {
+			return err
+		}
+		m[key] = val
+	}
+	return nil
+}
+
+// pointerTokens returns the reference tokens of the given JSON pointer (RFC 6901)
+func pointerTokens(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %#v", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func applyPatchOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value for %s operation on path %#v", op.Op, op.Path)
+		}
+		err := json.Unmarshal(*op.Value, &value)
+		if err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unsupported operation %#v", op.Op)
+	}
+
+	tokens, err := pointerTokens(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	return patchValue(doc, tokens, op, value)
+}
+
+// patchValue applies the operation to the value inside doc that the tokens refer to and
+// returns the modified doc
+func patchValue(doc interface{}, tokens []string, op patchOperation, value interface{}) (interface{}, error) {
+	key, last := tokens[0], len(tokens) == 1
+	notFound := fmt.Errorf("path %#v not found", op.Path)
+
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		child, has := d[key]
+		if !last {
+			if !has {
+				return nil, notFound
+			}
+			nc, err := patchValue(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			d[key] = nc
+			return d, nil
+		}
+		if !has && op.Op != "add" {
+			if op.Op == "test" {
+				return nil, &PatchTestError{op.Path}
+			}
+			return nil, notFound
+		}
+		switch op.Op {
+		case "add", "replace":
+			d[key] = value
+		case "remove":
+			delete(d, key)
+		case "test":
+			if !reflect.DeepEqual(child, value) {
+				return nil, &PatchTestError{op.Path}
+			}
+		}
+		return d, nil
+	case []interface{}:
+		if last && op.Op == "add" && key == "-" {
+			return append(d, value), nil
+		}
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(d) || (i == len(d) && !(last && op.Op == "add")) {
+			return nil, notFound
+		}
+		if !last {
+			nc, err := patchValue(d[i], tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = nc
+			return d, nil
+		}
+		switch op.Op {
+		case "add":
+			d = append(d, nil)
+			copy(d[i+1:], d[i:])
+			d[i] = value
+		case "replace":
+			d[i] = value
+		case "remove":
+			d = append(d[:i], d[i+1:]...)
+		case "test":
+			if !reflect.DeepEqual(d[i], value) {
+				return nil, &PatchTestError{op.Path}
+			}
+		}
+		return d, nil
+	}
+	return nil, notFound
+}
@@ -0,0 +1,119 @@
+package wsi
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type patchPerson struct {
+	Id   int
+	Name string
+	Age  int
+}
+
+func (p *patchPerson) ValidatePATCH() map[string]error {
+	if p.Name == "" {
+		return map[string]error{"Name": errors.New("missing")}
+	}
+	return nil
+}
+
+func TestJSONPatch(t *testing.T) {
+	load := func(r *http.Request) (Scanner, error) {
+		if PathParams(r)["id"] != 12 {
+			return nil, sql.ErrNoRows
+		}
+		return NewTestQuery([]string{"Id", "Name", "Age"},
+			map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian"), "Age": SetInt(30)}), nil
+	}
+
+	tests := []struct {
+		path, body string
+		status     int
+		m          map[string]interface{}
+	}{
+		{"/person/12", `[{"op":"replace","path":"/Name","value":"George"}]`, 200, map[string]interface{}{"Name": "George", "Id": 12}},
+		{"/person/12", `[{"op":"test","path":"/Age","value":30},{"op":"replace","path":"/Age","value":31}]`, 200, map[string]interface{}{"Age": 31, "Id": 12}},
+		{"/person/12", `[{"op":"remove","path":"/Age"}]`, 200, map[string]interface{}{"Age": nil, "Id": 12}},
+		{"/person/12", `[{"op":"replace","path":"/Name","value":"Adrian"}]`, 200, map[string]interface{}{"Id": 12}},
+		{"/person/12", `[{"op":"test","path":"/Age","value":31},{"op":"replace","path":"/Age","value":32}]`, 409, nil},
+		{"/person/12", `[{"op":"remove","path":"/Name"}]`, 400, nil},
+		{"/person/12", `[{"op":"replace","path":"/Unknown","value":1}]`, 400, nil},
+		{"/person/12", `[{"op":"move","from":"/Name","path":"/Age"}]`, 400, nil},
+		{"/person/13", `[{"op":"replace","path":"/Name","value":"George"}]`, 404, nil},
+	}
+
+	for i, test := range tests {
+		var got map[string]interface{}
+		fn := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			got = m
+			return nil
+		}
+		rt := Ressource{RessourceFunc: func() interface{} { return &patchPerson{} }}.Router("/person/{id:int}", Routes{Patch: fn})
+		rt.Patch = rt.Patch.(Exec).SetDecoder(NewJSONPatchDecoder(load))
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if !reflect.DeepEqual(got, test.m) {
+			t.Errorf("[%d] map = %#v, want: %#v", i, got, test.m)
+		}
+	}
+}
+
+// omitemptyPerson has an Age that is omitted from json, if it is 0
+type omitemptyPerson struct {
+	Id   int
+	Name string
+	Age  int `json:",omitempty"`
+}
+
+func TestJSONPatchOmitempty(t *testing.T) {
+	load := func(r *http.Request) (Scanner, error) {
+		return NewTestQuery([]string{"Id", "Name", "Age"},
+			map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian"), "Age": SetInt(0)}), nil
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		m      map[string]interface{}
+	}{
+		{`[{"op":"replace","path":"/Age","value":30}]`, 200, map[string]interface{}{"Age": 30, "Id": 12}},
+		{`[{"op":"test","path":"/Age","value":0},{"op":"replace","path":"/Age","value":31}]`, 200, map[string]interface{}{"Age": 31, "Id": 12}},
+	}
+
+	for i, test := range tests {
+		var got map[string]interface{}
+		fn := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			got = m
+			return nil
+		}
+		rt := Ressource{RessourceFunc: func() interface{} { return &omitemptyPerson{} }}.Router("/person/{id:int}", Routes{Patch: fn})
+		rt.Patch = rt.Patch.(Exec).SetDecoder(NewJSONPatchDecoder(load))
+
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/person/12", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json-patch+json")
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if !reflect.DeepEqual(got, test.m) {
+			t.Errorf("[%d] map = %#v, want: %#v", i, got, test.m)
+		}
+	}
+}
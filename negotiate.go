@@ -0,0 +1,81 @@
+package wsi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// mediaEncoder is an Encoder for a media type
+type mediaEncoder struct {
+	mediaType string
+	encFn     Encoder
+}
+
+// acceptRange is a media range of an Accept header with its quality
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges of the given Accept header
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		ar := acceptRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		if ar.mediaType == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				ar.q = q
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	return ranges
+}
+
+// quality returns the quality of the given media type for the given media ranges.
+// The most specific matching range wins (RFC 7231, section 5.3.2).
+func quality(mediaType string, ranges []acceptRange) float64 {
+	mainType := mediaType[:strings.Index(mediaType+"/", "/")]
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch ar.mediaType {
+		case mediaType:
+			s = 2
+		case mainType + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// negotiate returns the encoder with the highest quality for the given Accept header. On ties the
+// first encoder wins. Without an Accept header, the first encoder is returned.
+// ok is false, if no encoder is acceptable.
+func negotiate(accept string, encoders []mediaEncoder) (enc mediaEncoder, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return encoders[0], true
+	}
+	ranges := parseAccept(accept)
+	best := 0.0
+	for _, e := range encoders {
+		if q := quality(e.mediaType, ranges); q > best {
+			enc, best, ok = e, q, true
+		}
+	}
+	return
+}
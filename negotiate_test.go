@@ -0,0 +1,64 @@
+package wsi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type lineEncoder struct{ w http.ResponseWriter }
+
+func (l lineEncoder) Encode(v interface{}) error {
+	_, err := fmt.Fprintf(l.w, "%v\n", v)
+	return err
+}
+
+func (l lineEncoder) Finish() {}
+
+func newLineEncoder(w http.ResponseWriter) (StreamEncoder, error) {
+	return lineEncoder{w}, nil
+}
+
+func TestQueryContentNegotiation(t *testing.T) {
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery([]string{"Id", "Name"}, map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian")}), nil
+	}
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetEncoderFor("text/plain", newLineEncoder)
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", 200, "application/json; charset=utf-8"},
+		{"text/plain", 200, "text/plain"},
+		{"application/json;q=0.5, text/plain", 200, "text/plain"},
+		{"text/*;q=0.9, application/json;q=0.8", 200, "text/plain"},
+		{"*/*", 200, "application/json; charset=utf-8"},
+		{"application/json;q=0, */*", 200, "text/plain"},
+		{"text/*, text/plain;q=0", 406, "application/problem+json; charset=utf-8"},
+		{"image/png", 406, "application/problem+json; charset=utf-8"},
+	}
+
+	for i, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/person/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		q.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if got := rec.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("[%d] Content-Type = %#v, want: %#v", i, got, test.contentType)
+		}
+
+		if got := rec.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("[%d] Vary = %#v, want: %#v", i, got, "Accept")
+		}
+	}
+}
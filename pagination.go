@@ -0,0 +1,81 @@
+package wsi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CountFunc returns the total number of rows that match the given options, regardless of limit and offset.
+// It is used for the X-Total-Count header, see Query.SetCount.
+type CountFunc func(options QueryOptions, r *http.Request) (int, error)
+
+// QueryMeta is the meta data of the response of a Query
+type QueryMeta struct {
+	Limit  int  `json:"limit"`
+	Offset int  `json:"offset"`
+	Count  int  `json:"count"`
+	Total  *int `json:"total,omitempty"`
+
+	// Next is the url of the next page, if there may be one
+	Next string `json:"next,omitempty"`
+
+	// Cursor is the cursor of the next page in cursor mode
+	Cursor string `json:"cursor,omitempty"`
+
+	// Errors are the status texts of the errors that happened while streaming the rows; the errors
+	// themselves are passed to the error callback
+	Errors []string `json:"errors,omitempty"`
+}
+
+// MetaSetter may be implemented by a StreamEncoder that needs the meta data of the query.
+// SetMeta is called before Finish.
+type MetaSetter interface {
+	SetMeta(QueryMeta)
+}
+
+// paginationLinks returns the value for a Link header (RFC 5988) with the first, prev, next and last pages
+// for the given options. The urls are based on the given url.
+// If total is < 0, it is unknown: the next link is always included and the last link is missing.
+// If the options have no limit, there are no pages and an empty string is returned.
+func paginationLinks(u *url.URL, options QueryOptions, total int) string {
+	if options.Limit <= 0 {
+		return ""
+	}
+
+	links := []string{pageLink(u, options.Limit, 0, "first")}
+
+	if options.Offset > 0 {
+		prev := options.Offset - options.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(u, options.Limit, prev, "prev"))
+	}
+
+	next := options.Offset + options.Limit
+	if total < 0 || next < total {
+		links = append(links, pageLink(u, options.Limit, next, "next"))
+	}
+
+	if total > 0 {
+		last := ((total - 1) / options.Limit) * options.Limit
+		links = append(links, pageLink(u, options.Limit, last, "last"))
+	}
+
+	return strings.Join(links, ", ")
+}
+
+func pageLink(u *url.URL, limit, offset int, rel string) string {
+	return "<" + pageURL(u, limit, offset) + `>; rel="` + rel + `"`
+}
+
+// pageURL returns the path and query of the given url with the given limit and offset
+func pageURL(u *url.URL, limit, offset int) string {
+	values := u.Query()
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("offset", strconv.Itoa(offset))
+	page := url.URL{Path: u.Path, RawQuery: values.Encode()}
+	return page.String()
+}
@@ -0,0 +1,86 @@
+package wsi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryLinks(t *testing.T) {
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	count := func(total int) CountFunc {
+		return func(options QueryOptions, r *http.Request) (int, error) {
+			return total, nil
+		}
+	}
+
+	tests := []struct {
+		url   string
+		count CountFunc
+		link  string
+		total string
+	}{
+		{"/p?limit=0", nil, "", ""},
+		{"/p?limit=10", nil, `</p?limit=10&offset=0>; rel="first", </p?limit=10&offset=10>; rel="next"`, ""},
+		{
+			"/p?limit=10&offset=5&Name=x",
+			count(25),
+			`</p?Name=x&limit=10&offset=0>; rel="first", </p?Name=x&limit=10&offset=0>; rel="prev", </p?Name=x&limit=10&offset=15>; rel="next", </p?Name=x&limit=10&offset=20>; rel="last"`,
+			"25",
+		},
+		{
+			"/p?limit=10&offset=20",
+			count(25),
+			`</p?limit=10&offset=0>; rel="first", </p?limit=10&offset=10>; rel="prev", </p?limit=10&offset=20>; rel="last"`,
+			"25",
+		},
+		{"/p?limit=10", count(0), `</p?limit=10&offset=0>; rel="first"`, "0"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetLinks(true).SetCount(test.count).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Link"); got != test.link {
+			t.Errorf("%s => Link = %#v, want: %#v", test.url, got, test.link)
+		}
+
+		if got := rec.Header().Get("X-Total-Count"); got != test.total {
+			t.Errorf("%s => X-Total-Count = %#v, want: %#v", test.url, got, test.total)
+		}
+	}
+}
+
+func TestQueryCountErr(t *testing.T) {
+	var called bool
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		called = true
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	count := func(options QueryOptions, r *http.Request) (int, error) {
+		return 0, errors.New("count failed")
+	}
+
+	var err error
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { err = e }}.QueryWithOptions(fn).SetCount(count).ServeHTTP(rec, req)
+
+	if rec.Code != 500 {
+		t.Errorf("status = %v, want: %v", rec.Code, 500)
+	}
+
+	if err == nil || err.Error() != "count failed" {
+		t.Errorf("error callback got %v, want: count failed", err)
+	}
+
+	if called {
+		t.Errorf("QueryOptionsFunc should not be called if counting fails")
+	}
+}
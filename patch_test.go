@@ -0,0 +1,42 @@
+package wsi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecPatch(t *testing.T) {
+	tests := []struct {
+		method, body string
+		m            map[string]interface{}
+	}{
+		{"PATCH", `{"Name":"Adrian"}`, map[string]interface{}{"Name": "Adrian"}},
+		{"PATCH", `{"age":0}`, map[string]interface{}{"Age": 0}},
+		{"PATCH", `{"Name":null,"Age":3}`, map[string]interface{}{"Name": nil, "Age": 3}},
+		{"PATCH", `{}`, map[string]interface{}{}},
+		{"PUT", `{"Name":"Adrian"}`, map[string]interface{}{"Id": 0, "Name": "Adrian"}},
+	}
+
+	for i, test := range tests {
+		var got map[string]interface{}
+		fn := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			got = m
+			return nil
+		}
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/person/12", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		Ressource{RessourceFunc: newPersonData}.ServeExec(fn, rec, req)
+
+		if rec.Code != 200 {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, 200)
+		}
+
+		if !reflect.DeepEqual(got, test.m) {
+			t.Errorf("[%d] map = %#v, want: %#v", i, got, test.m)
+		}
+	}
+}
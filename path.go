@@ -0,0 +1,169 @@
+package wsi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a path pattern with parameters, e.g. /person/{id:int}.
+// A parameter is a whole path segment of the form {name} or {name:type}, where type is
+// "string" (the default) or "int". The name of a parameter should be the sql column name
+// (or the field name) of the ressource, since the parameters are passed to an ExecFunc under
+// the sql column name, see Exec. Names are matched case-insensitively, if there is no exact match.
+type Pattern struct {
+	pattern  string
+	segments []segment
+}
+
+type segment struct {
+	literal string
+	param   string
+	typ     string
+}
+
+var paramConverters = map[string]func(string) (interface{}, error){
+	"string": func(s string) (interface{}, error) { return s, nil },
+	"int":    func(s string) (interface{}, error) { return strconv.Atoi(s) },
+}
+
+// ParsePattern parses the given path pattern
+func ParsePattern(pattern string) (Pattern, error) {
+	p := Pattern{pattern: pattern}
+	for _, s := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(s, "{") {
+			if strings.ContainsAny(s, "{}") {
+				return Pattern{}, fmt.Errorf("invalid segment %#v in pattern %#v", s, pattern)
+			}
+			p.segments = append(p.segments, segment{literal: s})
+			continue
+		}
+		if !strings.HasSuffix(s, "}") {
+			return Pattern{}, fmt.Errorf("invalid segment %#v in pattern %#v", s, pattern)
+		}
+		sg := segment{param: s[1 : len(s)-1], typ: "string"}
+		if i := strings.Index(sg.param, ":"); i >= 0 {
+			sg.param, sg.typ = sg.param[:i], sg.param[i+1:]
+		}
+		if sg.param == "" {
+			return Pattern{}, fmt.Errorf("missing parameter name in pattern %#v", pattern)
+		}
+		if _, ok := paramConverters[sg.typ]; !ok {
+			return Pattern{}, fmt.Errorf("unknown type %#v of parameter %#v in pattern %#v", sg.typ, sg.param, pattern)
+		}
+		p.segments = append(p.segments, sg)
+	}
+	return p, nil
+}
+
+// MustParsePattern is like ParsePattern but panics on errors
+func MustParsePattern(pattern string) Pattern {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		panic(err.Error())
+	}
+	return p
+}
+
+func (p Pattern) String() string {
+	return p.pattern
+}
+
+// Match returns the converted parameters of the given path. ok is false, if the path does not match
+// or a parameter could not be converted to its type.
+func (p Pattern) Match(path string) (params map[string]interface{}, ok bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != len(p.segments) {
+		return nil, false
+	}
+	params = map[string]interface{}{}
+	for i, sg := range p.segments {
+		if sg.param == "" {
+			if parts[i] != sg.literal {
+				return nil, false
+			}
+			continue
+		}
+		if parts[i] == "" {
+			return nil, false
+		}
+		v, err := paramConverters[sg.typ](parts[i])
+		if err != nil {
+			return nil, false
+		}
+		params[sg.param] = v
+	}
+	return params, true
+}
+
+// Handler returns a http.Handler that passes the parameters of matching requests to h, see PathParams.
+// If the path of a request does not match, http.StatusNotFound is written.
+func (p Pattern) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, ok := p.Match(r.URL.Path)
+		if !ok {
+			ServeProblem(NewProblem(http.StatusNotFound, ""), w, r)
+			return
+		}
+		h.ServeHTTP(w, withPathParams(r, params))
+	})
+}
+
+type pathParamsKey struct{}
+
+// PathParams returns the parameters of the path of the request, if it was matched by a Pattern
+// (e.g. by a Router), or nil otherwise.
+func PathParams(r *http.Request) map[string]interface{} {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]interface{})
+	return params
+}
+
+func withPathParams(r *http.Request, params map[string]interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+}
+
+// convertPathParams converts the string parameters of the path to the type of the field of their column
+// (see columns.lookup). Parameters without column and parameters of fields of interface type are kept.
+func convertPathParams(params map[string]interface{}, mapper interface{}) (map[string]interface{}, error) {
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return nil, err
+	}
+	converted := make(map[string]interface{}, len(params))
+	for name, v := range params {
+		converted[name] = v
+		col, ok := cols.lookup(name)
+		s, isString := v.(string)
+		if !ok || !isString || col.Field.Type.Kind() == reflect.Interface {
+			continue
+		}
+		if converted[name], err = convertString(col.Field.Type, s); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// injectPathParams sets the parameters of the path inside the map of the given mapper under
+// their sql column names, overwriting the values of the body. Parameters are matched to columns and fields
+// like columns.lookup does. Parameters without column are set under their own name.
+func injectPathParams(r *http.Request, mapper interface{}, m map[string]interface{}) error {
+	params := PathParams(r)
+	if len(params) == 0 {
+		return nil
+	}
+	cols, err := sqlColumns(mapper)
+	if err != nil {
+		return err
+	}
+	for name, v := range params {
+		if col, ok := cols.lookup(name); ok {
+			name = col.Name
+		}
+		m[name] = v
+	}
+	return nil
+}
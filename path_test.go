@@ -0,0 +1,92 @@
+package wsi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		ok            bool
+		params        map[string]interface{}
+	}{
+		{"/person/{id:int}", "/person/12", true, map[string]interface{}{"id": 12}},
+		{"/person/{id:int}", "/person/abc", false, nil},
+		{"/person/{id:int}", "/person/", false, nil},
+		{"/person/{id:int}", "/person/12/", false, nil},
+		{"/person/{id:int}", "/book/12", false, nil},
+		{"/person/{name}/books/{id:int}", "/person/adrian/books/3", true, map[string]interface{}{"name": "adrian", "id": 3}},
+	}
+
+	for i, test := range tests {
+		params, ok := MustParsePattern(test.pattern).Match(test.path)
+		if ok != test.ok {
+			t.Errorf("[%d] ok = %v, want: %v", i, ok, test.ok)
+		}
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("[%d] params = %#v, want: %#v", i, params, test.params)
+		}
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	for _, pattern := range []string{"/person/{id:float}", "/person/{id", "/person/x{id}", "/person/{}"} {
+		if _, err := ParsePattern(pattern); err == nil {
+			t.Errorf("ParsePattern(%#v) returned no error", pattern)
+		}
+	}
+}
+
+type book struct {
+	ID    int    `sql:"id"`
+	Title string `sql:"title"`
+}
+
+func TestRouterPathParams(t *testing.T) {
+	var params, execMap map[string]interface{}
+	rt := Ressource{RessourceFunc: func() interface{} { return &book{} }}.Router("/book/{ID:int}", Routes{
+		Get: func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			params = PathParams(r)
+			return NewTestQuery([]string{"id", "title"}, map[string]Setter{"id": SetInt(3)}), nil
+		},
+		Replace: func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			execMap = m
+			return nil
+		},
+	})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		params, execMap    map[string]interface{}
+	}{
+		{"GET", "/book/3", "", 200, map[string]interface{}{"ID": 3}, nil},
+		{"GET", "/book/abc", "", 404, nil, nil},
+		{"PUT", "/book/3", `{"ID":5,"Title":"Go"}`, 200, nil, map[string]interface{}{"id": 3, "title": "Go"}},
+	}
+
+	for i, test := range tests {
+		params, execMap = nil, nil
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("[%d] params = %#v, want: %#v", i, params, test.params)
+		}
+		if !reflect.DeepEqual(execMap, test.execMap) {
+			t.Errorf("[%d] exec map = %#v, want: %#v", i, execMap, test.execMap)
+		}
+	}
+
+	if rt.Path != "/book/" {
+		t.Errorf("collection path = %#v, want: %#v", rt.Path, "/book/")
+	}
+}
@@ -0,0 +1,46 @@
+package wsi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is a problem details object (RFC 7807) that is served as application/problem+json
+// for all errors that are written by Query and Exec
+type Problem struct {
+	// Type is a URI that identifies the problem type. If empty, it is "about:blank"
+	// and the Title is the status text of the Status.
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Errors are the error messages keyed by field or query parameter
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// NewProblem returns a Problem for the given status with the status text as title
+func NewProblem(status int, detail string) Problem {
+	return Problem{Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// ServeProblem writes the given problem to the ResponseWriter. If the problem has no instance,
+// the path of the request is used.
+func ServeProblem(p Problem, w http.ResponseWriter, r *http.Request) error {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
+
+// errorMessages returns the messages of the given errors
+func errorMessages(errs map[string]error) map[string]string {
+	msgs := make(map[string]string, len(errs))
+	for k, err := range errs {
+		msgs[k] = err.Error()
+	}
+	return msgs
+}
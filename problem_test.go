@@ -0,0 +1,59 @@
+package wsi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type validPerson struct {
+	personData
+}
+
+func (v *validPerson) Validate() map[string]error {
+	if v.Name == "" {
+		return map[string]error{"Name": errors.New("missing")}
+	}
+	return nil
+}
+
+func TestExecProblem(t *testing.T) {
+	var called bool
+	fn := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+		called = true
+		return nil
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"Age":3}`, 400, `{"title":"Bad Request","status":400,"detail":"validation failed","instance":"/person/","errors":{"Name":"missing"}}`},
+		{`{"Age":`, 400, `{"title":"Bad Request","status":400,"detail":"invalid body","instance":"/person/"}`},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/person/", strings.NewReader(test.body))
+		Ressource{RessourceFunc: func() interface{} { return &validPerson{} }}.Exec(fn).ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s => status = %v, want: %v", test.body, rec.Code, test.status)
+		}
+
+		if got, want := rec.Header().Get("Content-Type"), "application/problem+json; charset=utf-8"; got != want {
+			t.Errorf("%s => Content-Type = %#v, want: %#v", test.body, got, want)
+		}
+
+		if got := rec.Body.String(); got != test.want+"\n" {
+			t.Errorf("%s => body = %#v, want: %#v", test.body, got, test.want+"\n")
+		}
+	}
+
+	if called {
+		t.Errorf("ExecFunc should not be called for invalid requests")
+	}
+}
@@ -1,44 +1,373 @@
 package wsi
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"github.com/go-on/builtin/db"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Encoder func(http.ResponseWriter) (StreamEncoder, error)
 
 type Query struct {
-	encFn        Encoder
-	mapperFn     func() interface{}
-	fn           QueryFunc
-	errorHandler func(*http.Request, error)
+	encFn       Encoder
+	encoders    []mediaEncoder
+	mapperFn    func() interface{}
+	fn          QueryOptionsFunc
+	legacy      bool // fn is a QueryFunc that only gets the limit and offset
+	limits      Limits
+	strict      bool
+	allowedKeys map[string]bool
+	cursor      *cursorConfig
+	links       bool
+	count       CountFunc
+	version     VersionFunc
+	conditional bool
+	errorHandling
 }
 
+type cursorConfig struct {
+	secret      []byte
+	defaultSort []SortField
+}
+
+// Limits defines the default and the maximal limit of a Query
+type Limits struct {
+	// Default is used, if no limit is requested. If Default is 0, Max is used instead.
+	Default int
+
+	// Max is the maximal limit, 0 means there is no maximum
+	Max int
+
+	// Reject lets requests with a limit greater than Max fail with http.StatusBadRequest.
+	// Otherwise the limit is reduced to Max.
+	Reject bool
+}
+
+// apply sets the limit of the given options according to the limits
+func (l Limits) apply(options *QueryOptions) error {
+	if options.Limit == 0 {
+		options.Limit = l.Default
+	}
+
+	if l.Max <= 0 {
+		return nil
+	}
+
+	switch {
+	case options.Limit == 0:
+		options.Limit = l.Max
+	case options.Limit > l.Max:
+		if l.Reject {
+			return fmt.Errorf("must not be greater than %d", l.Max)
+		}
+		options.Limit = l.Max
+	}
+	return nil
+}
+
+// QueryOptions are the options of a query, as requested by the url query values
 type QueryOptions struct {
 	Limit  int
 	Offset int
+
+	// Sort is the requested sorting in the order of priority
+	Sort []SortField
+
+	// Filter are the requested filters that must all match
+	Filter []Filter
+
+	// Fields are the requested columns. If empty, all columns are requested.
+	Fields []string
+
+	// Cursor is the decoded cursor of a request in cursor mode (see Query.SetCursor).
+	// If it is not nil, only the rows after the cursor must be returned (see SQLBuilder.WhereOptions).
+	Cursor *Cursor
+}
+
+// SortField is the sorting of a single column
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// QueryValuesError reports invalid url query values. The keys are the names of the
+// query parameters. It is served as json in the same format as validation errors.
+type QueryValuesError map[string]error
+
+func (q QueryValuesError) Error() string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = k + ": " + q[k].Error()
+	}
+	return "invalid query values: " + strings.Join(msgs, "; ")
+}
+
+func (q QueryValuesError) MarshalJSON() ([]byte, error) {
+	return errsMarshaller(q).MarshalJSON()
 }
 
+// SetEncoder sets the Encoder for application/json, which is the default if the request has no Accept header
 func (wq Query) SetEncoder(e Encoder) Query {
 	wq.encFn = e
 	return wq
 }
 
+// SetEncoderFor registers the Encoder for the given media type. The Encoder is chosen by the Accept header
+// of the request (with q-values). If no registered media type is acceptable, http.StatusNotAcceptable is written.
+// The Content-Type header is set to the chosen media type and the Vary header to Accept.
+func (wq Query) SetEncoderFor(mediaType string, e Encoder) Query {
+	mediaType = strings.ToLower(mediaType)
+	if mediaType == "application/json" {
+		return wq.SetEncoder(e)
+	}
+	encoders := make([]mediaEncoder, 0, len(wq.encoders)+1)
+	for _, me := range wq.encoders {
+		if me.mediaType != mediaType {
+			encoders = append(encoders, me)
+		}
+	}
+	wq.encoders = append(encoders, mediaEncoder{mediaType, e})
+	return wq
+}
+
+// mediaEncoders returns the registered encoders, starting with the default
+func (wq Query) mediaEncoders() []mediaEncoder {
+	return append([]mediaEncoder{{"application/json", wq.encFn}}, wq.encoders...)
+}
+
+// SetLimits sets the limits that are applied to the requested limit before the QueryFunc is called
+func (wq Query) SetLimits(l Limits) Query {
+	wq.limits = l
+	return wq
+}
+
+// SetStrict sets if invalid values for offset, limit and sort lead to a http.StatusBadRequest
+// (see ScanQueryValuesStrict) or if they are ignored (the default, see ScanQueryValues).
+func (wq Query) SetStrict(strict bool) Query {
+	wq.strict = strict
+	return wq
+}
+
+// SetAllowedKeys sets the url query keys that the QueryOptionsFunc reads itself, e.g. q for a full text search.
+// Other keys that are neither reserved (limit, offset, sort, fields, cursor) nor a column (or filter) of the
+// ressource lead to a http.StatusBadRequest, so that a typo like nmae=Adrian does not return all rows.
+func (wq Query) SetAllowedKeys(keys ...string) Query {
+	wq.allowedKeys = make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wq.allowedKeys[k] = true
+	}
+	return wq
+}
+
+// SetCursor enables the cursor mode for keyset pagination. If the number of rows of a response reaches the limit,
+// an opaque cursor that is signed with the given secret is sent in the X-Next-Cursor trailer and - if the
+// encoder is a MetaSetter like NewJSONEnvelope - inside the meta data. Since many clients (e.g. browsers)
+// can't read trailers, cursor mode should be used with NewJSONEnvelope (see SetEncoder).
+// The cursor refers to the values of the sort columns of the last row and must be passed as cursor query value to
+// get the next page. The sort columns are added to the requested Fields, so they must be part of the
+// scanned columns. Otherwise the error is reported like any error after the streaming started.
+// If a request has no sorting, defaultSort is used. It must not be empty and should end with a unique column.
+func (wq Query) SetCursor(secret []byte, defaultSort ...SortField) Query {
+	if len(defaultSort) == 0 {
+		panic("SetCursor needs a default sort")
+	}
+	wq.cursor = &cursorConfig{secret, defaultSort}
+	return wq
+}
+
+// SetLinks sets if a Link header (RFC 5988) with the first, prev, next and last pages is sent.
+// The pages are calculated by the limit and offset of the request and need a limit (see SetLimits).
+// Without a CountFunc (see SetCount) the next page is always linked and the last page is unknown.
+func (wq Query) SetLinks(links bool) Query {
+	wq.links = links
+	return wq
+}
+
+// SetCount sets the function that returns the total number of rows. The total is sent in the X-Total-Count header.
+func (wq Query) SetCount(fn CountFunc) Query {
+	wq.count = fn
+	return wq
+}
+
+// SetTimeout sets the maximal duration of a request. The context of the request that is passed to the
+// QueryFunc is canceled after the timeout, so that queries run by DBQueryContext are aborted.
+// If the timeout is exceeded before anything has been written, http.StatusGatewayTimeout is written
+// and a *TimeoutError is passed to the error callback.
+// A timeout of 0 means no timeout.
+func (wq Query) SetTimeout(d time.Duration) Query {
+	wq.timeout = d
+	return wq
+}
+
+// SetUnavailable sets the function that classifies errors as unavailability of the database, e.g. an exhausted
+// connection pool. For such errors http.StatusServiceUnavailable is written, if nothing has been written before,
+// and a *TimeoutError is passed to the error callback.
+func (wq Query) SetUnavailable(fn func(error) bool) Query {
+	wq.unavailable = fn
+	return wq
+}
+
+// SetErrorMapper sets the ErrorMapper that writes the response for errors that happen before
+// anything has been written, see DefaultErrorMapper
+func (wq Query) SetErrorMapper(m ErrorMapper) Query {
+	wq.errorMapper = m
+	return wq
+}
+
+// SetVersion sets the function that returns the version of the data. It is sent as weak ETag and
+// requests with a matching If-None-Match header are answered with http.StatusNotModified before the QueryFunc is called.
+func (wq Query) SetVersion(fn VersionFunc) Query {
+	wq.version = fn
+	return wq
+}
+
+// SetConditional sets if the rows are scanned before anything is written, so that a weak ETag over the rows
+// (if no VersionFunc is set) and a Last-Modified header are sent. The Last-Modified header is the latest time of
+// the column with the lastmodified option, e.g. `sql:"updated_at,lastmodified"`.
+// Requests with matching If-None-Match or If-Modified-Since headers are answered with http.StatusNotModified
+// without encoding the rows.
+func (wq Query) SetConditional(conditional bool) Query {
+	wq.conditional = conditional
+	return wq
+}
+
 func (wq Query) SetErrorCallback(fn func(*http.Request, error)) Query {
 	wq.errorHandler = fn
 	return wq
 }
 
+// options returns the QueryOptions of the given request with the limits applied
+func (wq Query) options(r *http.Request) (options QueryOptions, err error) {
+	if wq.legacy {
+		// a QueryFunc can't see sorting, fields and filters, so they are not checked against the columns
+		// and the rows are encoded with all fields
+		var errs QueryValuesError
+		options, errs = scanQueryValues(r.URL.Query(), wq.strict)
+		options.Fields = nil
+		if len(errs) > 0 {
+			err = errs
+		}
+	} else {
+		options, err = scanQueryValuesFor(r.URL.Query(), wq.mapperFn(), wq.strict, wq.allowedKeys)
+	}
+	errs, isValuesErr := err.(QueryValuesError)
+	if err != nil && !isValuesErr {
+		return
+	}
+
+	if errs == nil {
+		errs = QueryValuesError{}
+	}
+
+	if limitErr := wq.limits.apply(&options); limitErr != nil {
+		errs["limit"] = limitErr
+	}
+
+	if wq.cursor != nil {
+		if len(options.Sort) == 0 {
+			options.Sort = wq.cursor.defaultSort
+		}
+
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			options.Cursor, err = decodeCursor(wq.cursor.secret, c, wq.mapperFn())
+			switch {
+			case err != nil:
+				errs["cursor"] = err
+			case !reflect.DeepEqual(options.Cursor.Sort, options.Sort):
+				errs["cursor"] = fmt.Errorf("cursor does not match the sorting")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return options, errs
+	}
+	return options, nil
+}
+
 func (wq Query) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	scanner, err := QueryByRequest(w, r, wq.fn)
-	// if we got an error here, the status code has already be written
+	r, cancel := withTimeout(r, wq.timeout)
+	defer cancel()
+
+	tw := &writeTracker{ResponseWriter: w}
+	w = tw
+
+	encoders := wq.mediaEncoders()
+	me, acceptable := negotiate(r.Header.Get("Accept"), encoders)
+	w.Header().Add("Vary", "Accept")
+	if !acceptable {
+		types := make([]string, len(encoders))
+		for i, e := range encoders {
+			types[i] = e.mediaType
+		}
+		ServeProblem(NewProblem(http.StatusNotAcceptable, "available media types: "+strings.Join(types, ", ")), w, r)
+		return
+	}
+
+	options, err := wq.options(r)
+	if err != nil {
+		wq.fail(w, r, err)
+		return
+	}
+
+	// the requested fields are encoded, the cursor needs the sort columns in addition
+	fields := options.Fields
+	if wq.cursor != nil && len(fields) > 0 {
+		options.Fields = withSortColumns(fields, options.Sort)
+	}
+
+	var etag string
+	if wq.version != nil {
+		var version string
+		version, err = wq.version(r)
+		if err != nil {
+			wq.fail(w, r, err)
+			return
+		}
+		etag = weakETag(version)
+		if notModified(r, etag, time.Time{}) {
+			setValidators(w, etag, time.Time{})
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	total := -1
+	if wq.count != nil {
+		total, err = wq.count(options, r)
+		if err != nil {
+			wq.fail(w, r, err)
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	}
+
+	if wq.links {
+		if links := paginationLinks(r.URL, options, total); links != "" {
+			w.Header().Set("Link", links)
+		}
+	}
+
+	scanner, err := wq.fn(options, w, r)
 	if err != nil {
-		if wq.errorHandler != nil {
-			wq.errorHandler(r, err)
+		if !tw.written {
+			wq.fail(w, r, err)
+			return
 		}
+		wq.callback(r, err)
 		return
 	}
 	defer scanner.Close()
@@ -46,44 +375,193 @@ func (wq Query) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// we could not construct the scanner properly. fail early.
 	err = scanner.Error()
 	if err != nil {
-		if wq.errorHandler != nil {
-			wq.errorHandler(r, err)
-		}
-		w.WriteHeader(http.StatusInternalServerError)
+		wq.fail(w, r, err)
 		return
 	}
 
+	next := scanRows(scanner, wq.mapperFn)
+	if wq.conditional {
+		var rows []interface{}
+		rows, err = bufferRows(scanner, wq.mapperFn)
+		if err != nil {
+			wq.fail(w, r, err)
+			return
+		}
+
+		var lastMod time.Time
+		for _, row := range rows {
+			if t, ok := lastModified(row); ok && t.After(lastMod) {
+				lastMod = t
+			}
+		}
+
+		if etag == "" {
+			etag, err = rowsETag(rows, fields, total)
+			if err != nil {
+				wq.fail(w, r, err)
+				return
+			}
+		}
+
+		setValidators(w, etag, lastMod)
+		if notModified(r, etag, lastMod) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next = bufferedRows(rows)
+	} else {
+		// fetch the first row before anything is written, so that e.g. a timeout of the query
+		// leads to a proper status code instead of a truncated body
+		var first interface{}
+		first, err = next()
+		if err != nil {
+			wq.fail(w, r, err)
+			return
+		}
+		next = withFirstRow(first, next)
+		setValidators(w, etag, time.Time{})
+	}
+
+	w.Header().Add("Trailer", "X-Stream-Error")
+	if wq.cursor != nil {
+		w.Header().Add("Trailer", "X-Next-Cursor")
+	}
+
 	var enc StreamEncoder
-	enc, err = wq.encFn(w)
+	w.Header().Set("Content-Type", me.mediaType)
+	enc, err = me.encFn(w)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		if wq.errorHandler != nil {
-			wq.errorHandler(r, err)
+		if !tw.written {
+			wq.fail(w, r, err)
+			return
 		}
+		wq.callback(r, err)
 		return
 	}
 
-	defer enc.Finish()
+	meta := QueryMeta{Limit: options.Limit, Offset: options.Offset}
+	if total >= 0 {
+		meta.Total = &total
+	}
 
-	for scanner.Next() {
-		mapper := wq.mapperFn()
+	var abortErr error
 
-		err = ScanToMapper(scanner, mapper)
+	defer func() {
+		if ms, ok := enc.(MetaSetter); ok {
+			ms.SetMeta(meta)
+		}
+		if a, ok := enc.(Aborter); ok && abortErr != nil {
+			a.Abort(abortErr)
+			return
+		}
+		enc.Finish()
+	}()
+
+	// we already wrote something to the body, so the status code can't be changed anymore.
+	// the error is reported via the X-Stream-Error trailer and the encoder is aborted instead of finished.
+	// the message of the error is not exposed, only the status text of the status the ErrorMapper returns.
+	streamErr := func(err error) {
+		if te := timeoutError(r, wq.timeout, wq.unavailable, err); te != nil {
+			err = te
+		}
+		abortErr = err
+		msg := streamErrorMessage(wq.errorMapper, err)
+		meta.Errors = append(meta.Errors, msg)
+		w.Header().Set("X-Stream-Error", msg)
+		wq.callback(r, err)
+	}
+
+	var last interface{}
+
+	for {
+		var mapper interface{}
+		mapper, err = next()
+		if err != nil {
+			streamErr(err)
+			return
+		}
+		if mapper == nil {
+			break
+		}
+
+		var v interface{} = mapper
+		if len(fields) > 0 {
+			v, err = selectJSONFields(mapper, fields)
+			if err != nil {
+				streamErr(err)
+				return
+			}
+		}
+
+		err = enc.Encode(v)
+		if err != nil {
+			streamErr(err)
+			return
+		}
+		last = mapper
+		meta.Count++
+	}
 
-		// we already wrote something to the body, so handle errors gracefully
-		if err != nil && wq.errorHandler != nil {
-			wq.errorHandler(r, err)
+	// there may be more rows
+	if options.Limit > 0 && meta.Count >= options.Limit {
+		if wq.cursor == nil {
+			meta.Next = pageURL(r.URL, options.Limit, options.Offset+options.Limit)
 			return
 		}
 
-		// we already wrote something to the body, so handle errors gracefully
-		err = enc.Encode(mapper)
-		if err != nil && wq.errorHandler != nil {
-			wq.errorHandler(r, err)
+		var next string
+		next, err = wq.nextCursor(last, options.Sort, scanner.Columns())
+		if err != nil {
+			streamErr(err)
 			return
 		}
+		w.Header().Set("X-Next-Cursor", next)
+		meta.Cursor = next
+		values := r.URL.Query()
+		values.Set("cursor", next)
+		meta.Next = (&url.URL{Path: r.URL.Path, RawQuery: values.Encode()}).String()
+	}
+}
+
+// withSortColumns returns the given fields with the columns of the given sorting added
+func withSortColumns(fields []string, sort []SortField) []string {
+	res := append([]string{}, fields...)
+	for _, s := range sort {
+		var has bool
+		for _, f := range res {
+			if f == s.Column {
+				has = true
+				break
+			}
+		}
+		if !has {
+			res = append(res, s.Column)
+		}
+	}
+	return res
+}
+
+// nextCursor returns the encoded cursor for the given last row. Every sort column must be one of
+// the scanned columns, since the values of other columns are unknown.
+func (wq Query) nextCursor(last interface{}, sort []SortField, scanned []string) (string, error) {
+	for _, s := range sort {
+		var has bool
+		for _, col := range scanned {
+			if col == s.Column {
+				has = true
+				break
+			}
+		}
+		if !has {
+			return "", fmt.Errorf("sort column %s has not been scanned", s.Column)
+		}
+	}
+	c, err := cursorOf(last, sort)
+	if err != nil {
+		return "", err
 	}
+	return encodeCursor(wq.cursor.secret, c)
 }
 
 // QueryByRequest returns a Scanner with the help of the given search function and parametrized by the given request.
@@ -95,40 +573,178 @@ func QueryByRequest(w http.ResponseWriter, r *http.Request, fn QueryFunc) (scann
 	return fn(options.Limit, options.Offset, w, r)
 }
 
-// ScanQueryValues scans the query values "offset", "limit" and "sort" out of the given url.Values.
+// ScanQueryValues scans the query values "offset", "limit", "sort" and "fields" out of the given url.Values.
 //   offset - if set - must be convertible to an int, defaults to 0 (=no skipping)
 //   limit - if set - must be convertible to an int, defaults to maxLimit
 //   sort must be in the form "+col" or "-col" where "+col" results in ascending sort of the col and -col results in descending sorting.
 //        Multiple query values for sort resulting in mutliple sorts in the order of the values
+//        A column without prefix is sorted ascending, empty values are ignored.
+//   fields is a comma separated list of the requested columns, it may be given multiple times.
+// ScanQueryValues is lenient: invalid or negative values for offset and limit are treated as 0.
+// See ScanQueryValuesStrict for a version that reports them.
+// ScanQueryValues does not check if the sort columns and fields exist, see ScanQueryValuesFor.
 func ScanQueryValues(values url.Values) (options QueryOptions) {
-	options.Offset, _ = strconv.Atoi(values.Get("offset"))
-	options.Limit, _ = strconv.Atoi(values.Get("limit"))
+	options, _ = scanQueryValues(values, false)
+	return
+}
+
+// ScanQueryValuesStrict is like ScanQueryValues, but returns a QueryValuesError if the value of
+// offset or limit is not a positive int or if a sort value has no column.
+func ScanQueryValuesStrict(values url.Values) (options QueryOptions, err error) {
+	options, errs := scanQueryValues(values, true)
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
 
-	if options.Limit < 0 {
-		options.Limit = 0
+// scanQueryValues scans the values as described in ScanQueryValues. If strict is true,
+// invalid values are reported inside the returned errors.
+func scanQueryValues(values url.Values, strict bool) (options QueryOptions, errs QueryValuesError) {
+	errs = QueryValuesError{}
+
+	options.Offset = scanPositiveInt(values, "offset", strict, errs)
+	options.Limit = scanPositiveInt(values, "limit", strict, errs)
+
+	for _, s := range values["sort"] {
+		var desc bool
+		switch {
+		// an unescaped + inside the url is decoded to a space
+		case strings.HasPrefix(s, "+"), strings.HasPrefix(s, " "):
+			s = s[1:]
+		case strings.HasPrefix(s, "-"):
+			desc = true
+			s = s[1:]
+		}
+		if s == "" {
+			if strict {
+				errs["sort"] = fmt.Errorf("missing column")
+			}
+			continue
+		}
+		options.Sort = append(options.Sort, SortField{Column: s, Desc: desc})
 	}
 
-	// offset must be >= 0
-	if options.Offset < 0 {
-		options.Offset = 0
+	for _, f := range values["fields"] {
+		for _, col := range strings.Split(f, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				options.Fields = append(options.Fields, col)
+			}
+		}
 	}
 
 	return
 }
 
+// scanPositiveInt returns the int value for the given key or 0, if there is none
+// or if it is invalid. If strict is true, invalid values are reported inside errs.
+func scanPositiveInt(values url.Values, key string, strict bool, errs QueryValuesError) int {
+	v := values.Get(key)
+	if v == "" {
+		return 0
+	}
+
+	i, err := strconv.Atoi(v)
+
+	switch {
+	case err != nil:
+		if strict {
+			errs[key] = fmt.Errorf("%#v is no int", v)
+		}
+		return 0
+	case i < 0:
+		if strict {
+			errs[key] = fmt.Errorf("must not be negative")
+		}
+		return 0
+	}
+
+	return i
+}
+
+// ScanQueryValuesFor scans the query values like ScanQueryValues and checks them against the columns
+// of the struct structPtr points to (see MapSQL). Additionally the filters are scanned: every other query key
+// that is a column or a column followed by __ and an operator (eq, ne, lt, lte, gt, gte, in, like, isnull), e.g.
+//   name=Adrian&age__gt=30&id__in=1,2,3&notes__isnull=true
+// The values are converted to the type of the field of the column.
+// If a value refers to an unknown column or can't be converted, a QueryValuesError is returned.
+func ScanQueryValuesFor(values url.Values, structPtr interface{}) (options QueryOptions, err error) {
+	return scanQueryValuesFor(values, structPtr, false, nil)
+}
+
+// scanQueryValuesFor does the work for ScanQueryValuesFor. If strict is true, offset, limit and sort
+// are scanned like ScanQueryValuesStrict does. The allowed keys are no filters and are not checked.
+func scanQueryValuesFor(values url.Values, structPtr interface{}, strict bool, allowed map[string]bool) (options QueryOptions, err error) {
+	options, errs := scanQueryValues(values, strict)
+
+	var cols columns
+	cols, err = sqlColumns(structPtr)
+	if err != nil {
+		return
+	}
+
+	var unknown []string
+
+	for _, s := range options.Sort {
+		if !cols.has(s.Column) {
+			unknown = append(unknown, s.Column)
+		}
+	}
+
+	if len(unknown) > 0 {
+		errs["sort"] = fmt.Errorf("unknown column(s): %s", strings.Join(unknown, ", "))
+	}
+
+	unknown = nil
+	for _, f := range options.Fields {
+		if !cols.has(f) {
+			unknown = append(unknown, f)
+		}
+	}
+
+	if len(unknown) > 0 {
+		errs["fields"] = fmt.Errorf("unknown column(s): %s", strings.Join(unknown, ", "))
+	}
+
+	options.Filter = scanFilters(values, cols, allowed, errs)
+
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
 // DBQuery returns a Scanner for the given query that allowes iteration over the returned rows from
 // the underlying sql query. The Scanner takes of closing the rows
 func DBQuery(d db.DB, query string, values ...interface{}) (sc Scanner, err error) {
-	var (
-		rows *sql.Rows
-		cols []string
-	)
-
+	var rows *sql.Rows
 	rows, err = d.Query(query, values...)
 	if err != nil {
 		return
 	}
+	return newDBScanner(rows)
+}
+
+// ContextDB is a database that supports contexts, like *sql.DB, *sql.Tx and *sql.Conn
+type ContextDB interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// DBQueryContext is like DBQuery but runs the query with the given context.
+// If the context is canceled (e.g. because the client went away), the query is aborted and
+// the Error method of the Scanner returns the error of the context.
+// Inside a QueryFunc, the context of the request should be used (r.Context()).
+func DBQueryContext(ctx context.Context, d ContextDB, query string, values ...interface{}) (sc Scanner, err error) {
+	var rows *sql.Rows
+	rows, err = d.QueryContext(ctx, query, values...)
+	if err != nil {
+		return
+	}
+	return newDBScanner(rows)
+}
 
+func newDBScanner(rows *sql.Rows) (sc Scanner, err error) {
+	var cols []string
 	cols, err = rows.Columns()
 	if err != nil {
 		return
@@ -140,6 +756,6 @@ func DBQuery(d db.DB, query string, values ...interface{}) (sc Scanner, err erro
 		columns[col] = i
 	}
 
-	sc = &dbScanner{columns: columns, Rows: rows}
+	sc = &dbScanner{columns: columns, cols: cols, Rows: rows}
 	return
 }
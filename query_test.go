@@ -2,10 +2,13 @@ package wsi
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/go-on/builtin"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/go-on/pq"
@@ -54,6 +57,17 @@ func newPersonMapper() interface{} {
 	return &person{}
 }
 
+// personData is a person without unexported fields, so that it can be used with MapSQL, e.g. for Exec
+type personData struct {
+	Id   int
+	Name string
+	Age  int `json:",omitempty" sql:",omitempty"`
+}
+
+func newPersonData() interface{} {
+	return &personData{}
+}
+
 func (p *person) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	errHandler := func(rr *http.Request, err error) { p.err = err }
 	var fn func(limit, offset int, w http.ResponseWriter, r *http.Request) (Scanner, error)
@@ -68,7 +82,7 @@ func (p *person) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		fn = searchPersonErr
 	}
 
-	Ressource{newPersonMapper, errHandler}.ServeQuery(fn, w, r)
+	Ressource{RessourceFunc: newPersonMapper, ErrorHandler: errHandler}.ServeQuery(fn, w, r)
 }
 
 func init() {
@@ -187,3 +201,450 @@ func TestQueryRun(t *testing.T) {
 
 	}
 }
+
+func TestQuerySort(t *testing.T) {
+	var got []SortField
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options.Sort
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	tests := []struct {
+		url    string
+		status int
+		sort   []SortField
+	}{
+		{"/", 200, nil},
+		{"/?sort=%2BName", 200, []SortField{{"Name", false}}},
+		{"/?sort=+Name", 200, []SortField{{"Name", false}}},
+		{"/?sort=-Age&sort=Id", 200, []SortField{{"Age", true}, {"Id", false}}},
+		{"/?sort=-unknown", 400, nil},
+		{"/?sort=err", 400, nil},
+	}
+
+	for _, test := range tests {
+		got = nil
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.ServeQueryWithOptions(fn, rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s => status = %v, want: %v", test.url, rec.Code, test.status)
+		}
+
+		if !reflect.DeepEqual(got, test.sort) {
+			t.Errorf("%s => sort = %#v, want: %#v", test.url, got, test.sort)
+		}
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	var got []Filter
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options.Filter
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	tests := []struct {
+		url     string
+		status  int
+		filters []Filter
+		body    string
+	}{
+		{"/?limit=2&custom=x", 400, nil, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"custom":"unknown column \"custom\""}}` + "\n"},
+		{"/?Name=Adrian&Age__gt=30", 200, []Filter{{"Age", OpGt, 30}, {"Name", OpEq, "Adrian"}}, ""},
+		{"/?Id__in=1,2&Notes__isnull=1", 200, []Filter{{"Id", OpIn, []interface{}{1, 2}}, {"Notes", OpIsNull, true}}, ""},
+		{"/?Notes__like=a%25", 200, []Filter{{"Notes", OpLike, "a%"}}, ""},
+		{"/?Age=x", 400, nil, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"Age":"\"x\" is no int"}}` + "\n"},
+		{"/?Age__between=1", 400, nil, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"Age__between":"unknown operator \"between\""}}` + "\n"},
+		{"/?unknown__eq=1", 400, nil, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"unknown__eq":"unknown column \"unknown\""}}` + "\n"},
+	}
+
+	for _, test := range tests {
+		got = nil
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.ServeQueryWithOptions(fn, rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s => status = %v, want: %v", test.url, rec.Code, test.status)
+		}
+
+		if !reflect.DeepEqual(got, test.filters) {
+			t.Errorf("%s => filters = %#v, want: %#v", test.url, got, test.filters)
+		}
+
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("%s => body = %#v, want: %#v", test.url, rec.Body.String(), test.body)
+		}
+	}
+}
+
+func TestQueryAllowedKeys(t *testing.T) {
+	var got []Filter
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options.Filter
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?nmae=Adrian&limit=2", nil)
+	q.ServeHTTP(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("unknown key => status = %v, want: %v", rec.Code, 400)
+	}
+
+	if got, want := rec.Body.String(), `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"nmae":"unknown column \"nmae\""}}`+"\n"; got != want {
+		t.Errorf("unknown key => body = %#v, want: %#v", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?q=Adrian&Age=30", nil)
+	q.SetAllowedKeys("q").ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("allowed key => status = %v, want: %v", rec.Code, 200)
+	}
+
+	if want := []Filter{{"Age", OpEq, 30}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("allowed key => filters = %#v, want: %#v", got, want)
+	}
+}
+
+func TestQueryFields(t *testing.T) {
+	var got []string
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options.Fields
+		return NewTestQuery(
+			[]string{"Id", "Name"},
+			map[string]Setter{"Id": SetInt(1), "Name": SetString("Adrian")},
+		), nil
+	}
+
+	tests := []struct {
+		url    string
+		status int
+		fields []string
+		body   string
+	}{
+		{"/", 200, nil, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?fields=Name", 200, []string{"Name"}, `[{"Name":"Adrian"}` + "\n]"},
+		{"/?fields=Name,Id", 200, []string{"Name", "Id"}, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?fields=Id&fields=Age", 200, []string{"Id", "Age"}, `[{"Id":1}` + "\n]"},
+		{"/?fields=Id,unknown", 400, nil, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"fields":"unknown column(s): unknown"}}` + "\n"},
+	}
+
+	for _, test := range tests {
+		got = nil
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.ServeQueryWithOptions(fn, rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s => status = %v, want: %v", test.url, rec.Code, test.status)
+		}
+
+		if !reflect.DeepEqual(got, test.fields) {
+			t.Errorf("%s => fields = %#v, want: %#v", test.url, got, test.fields)
+		}
+
+		if rec.Body.String() != test.body {
+			t.Errorf("%s => body = %#v, want: %#v", test.url, rec.Body.String(), test.body)
+		}
+	}
+}
+
+func TestLegacyQueryOptions(t *testing.T) {
+	fn := func(limit, offset int, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery(
+			[]string{"Id", "Name"},
+			map[string]Setter{"Id": SetInt(1), "Name": SetString("Adrian")},
+		), nil
+	}
+
+	tests := []struct {
+		url    string
+		strict bool
+		status int
+		body   string
+	}{
+		{"/?sort=foo", false, 200, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?sort=foo", true, 200, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?fields=Name,unknown", true, 200, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?Id=abc&x__y=1", false, 200, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?Id=abc&nmae=Adrian", true, 200, `[{"Id":1,"Name":"Adrian"}` + "\n]"},
+		{"/?sort=-", true, 400, `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"sort":"missing column"}}` + "\n"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.Query(fn).SetStrict(test.strict).ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s (strict: %v) => status = %v, want: %v", test.url, test.strict, rec.Code, test.status)
+		}
+
+		if rec.Body.String() != test.body {
+			t.Errorf("%s (strict: %v) => body = %#v, want: %#v", test.url, test.strict, rec.Body.String(), test.body)
+		}
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	var got int
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options.Limit
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	tests := []struct {
+		url    string
+		limits Limits
+		status int
+		limit  int
+	}{
+		{"/", Limits{}, 200, 0},
+		{"/", Limits{Default: 10, Max: 30}, 200, 10},
+		{"/", Limits{Max: 30}, 200, 30},
+		{"/?limit=20", Limits{Default: 10, Max: 30}, 200, 20},
+		{"/?limit=40", Limits{Default: 10, Max: 30}, 200, 30},
+		{"/?limit=40", Limits{Default: 10, Max: 30, Reject: true}, 400, -1},
+		{"/?limit=40", Limits{Default: 10}, 200, 40},
+	}
+
+	for _, test := range tests {
+		got = -1
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.url, nil)
+		Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetLimits(test.limits).ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s %+v => status = %v, want: %v", test.url, test.limits, rec.Code, test.status)
+		}
+
+		if got != test.limit {
+			t.Errorf("%s %+v => limit = %v, want: %v", test.url, test.limits, got, test.limit)
+		}
+	}
+}
+
+func TestScanQueryValuesStrict(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"limit=10&offset=20&sort=-Id", ""},
+		{"limit=abc", `invalid query values: limit: "abc" is no int`},
+		{"offset=1e9x&limit=-2", `invalid query values: limit: must not be negative; offset: "1e9x" is no int`},
+		{"sort=-", `invalid query values: sort: missing column`},
+	}
+
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+
+		lenient := ScanQueryValues(values)
+		options, err := ScanQueryValuesStrict(values)
+
+		if !reflect.DeepEqual(lenient, options) {
+			t.Errorf("%s => options = %#v, want: %#v", test.query, options, lenient)
+		}
+
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != test.err {
+			t.Errorf("%s => err = %#v, want: %#v", test.query, got, test.err)
+		}
+	}
+}
+
+func TestQueryStrict(t *testing.T) {
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?limit=abc&offset=2", nil)
+	q.ServeHTTP(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("lenient query => status = %v, want: %v", rec.Code, 200)
+	}
+
+	rec = httptest.NewRecorder()
+	q.SetStrict(true).ServeHTTP(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("strict query => status = %v, want: %v", rec.Code, 400)
+	}
+
+	if got, want := rec.Body.String(), `{"title":"Bad Request","status":400,"detail":"invalid query values","instance":"/","errors":{"limit":"\"abc\" is no int"}}`+"\n"; got != want {
+		t.Errorf("strict query => body = %#v, want: %#v", got, want)
+	}
+}
+
+func TestQueryCursor(t *testing.T) {
+	var got QueryOptions
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options
+		return NewTestQuery(
+			[]string{"Id", "Name"},
+			map[string]Setter{"Id": SetInt(1), "Name": SetString("Adrian")},
+			map[string]Setter{"Id": SetInt(2), "Name": SetString("George")},
+		), nil
+	}
+
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetCursor([]byte("secret"), SortField{"Id", false})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?limit=2&sort=-Name", nil)
+	q.ServeHTTP(rec, req)
+
+	next := rec.Result().Trailer.Get("X-Next-Cursor")
+	if next == "" {
+		t.Fatalf("missing X-Next-Cursor trailer")
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?limit=2&sort=-Name&cursor="+next, nil)
+	q.ServeHTTP(rec, req)
+
+	want := &Cursor{Sort: []SortField{{"Name", true}}, Values: []interface{}{"George"}}
+	if !reflect.DeepEqual(got.Cursor, want) {
+		t.Errorf("cursor = %#v, want: %#v", got.Cursor, want)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?limit=3", nil)
+	q.ServeHTTP(rec, req)
+
+	if !reflect.DeepEqual(got.Sort, []SortField{{"Id", false}}) {
+		t.Errorf("default sort = %#v, want: %#v", got.Sort, []SortField{{"Id", false}})
+	}
+
+	if next := rec.Result().Trailer.Get("X-Next-Cursor"); next != "" {
+		t.Errorf("last page should have no cursor, got %#v", next)
+	}
+
+	for _, url := range []string{"/?sort=Id&cursor=" + next, "/?sort=-Name&cursor=" + next + "x", "/?cursor=abc"} {
+		rec = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", url, nil)
+		q.ServeHTTP(rec, req)
+
+		if rec.Code != 400 {
+			t.Errorf("%s => status = %v, want: %v", url, rec.Code, 400)
+		}
+	}
+}
+
+func TestQueryCursorFields(t *testing.T) {
+	var got QueryOptions
+	cols := []string{"Id", "Name"}
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		got = options
+		return NewTestQuery(
+			cols,
+			map[string]Setter{"Id": SetInt(1), "Name": SetString("Adrian")},
+			map[string]Setter{"Id": SetInt(2), "Name": SetString("George")},
+		), nil
+	}
+
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetCursor([]byte("secret"), SortField{"Id", false})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?fields=Name&limit=2", nil)
+	q.ServeHTTP(rec, req)
+
+	if !reflect.DeepEqual(got.Fields, []string{"Name", "Id"}) {
+		t.Errorf("fields = %#v, want: %#v", got.Fields, []string{"Name", "Id"})
+	}
+
+	if body, want := rec.Body.String(), "[{\"Name\":\"Adrian\"}\n,{\"Name\":\"George\"}\n]"; body != want {
+		t.Errorf("body = %#v, want: %#v", body, want)
+	}
+
+	next := rec.Result().Trailer.Get("X-Next-Cursor")
+	c, err := decodeCursor([]byte("secret"), next, &person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Values, []interface{}{2}) {
+		t.Errorf("cursor values = %#v, want: %#v", c.Values, []interface{}{2})
+	}
+
+	// the QueryFunc did not scan the sort column
+	cols = []string{"Name"}
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?fields=Name&limit=2", nil)
+	q.ServeHTTP(rec, req)
+
+	if next := rec.Result().Trailer.Get("X-Next-Cursor"); next != "" {
+		t.Errorf("cursor without scanned sort column = %#v, want none", next)
+	}
+	if rec.Result().Trailer.Get("X-Stream-Error") == "" {
+		t.Errorf("missing X-Stream-Error trailer")
+	}
+}
+
+func TestSetCursorWithoutDefaultSort(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SetCursor without default sort did not panic")
+		}
+	}()
+	Ressource{RessourceFunc: newPersonMapper}.Query(func(limit, offset int, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return nil, nil
+	}).SetCursor([]byte("secret"))
+}
+
+func TestQueryStreamError(t *testing.T) {
+	newScanner := func() Scanner {
+		var n int
+		return NewTestScanner([]string{"Id"}, func(targets map[string]interface{}) (bool, error) {
+			n++
+			if n > 1 {
+				return true, errors.New("connection lost")
+			}
+			*(targets["Id"].(*int)) = n
+			return false, nil
+		})
+	}
+
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return newScanner(), nil
+	}
+
+	var err error
+	q := Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { err = e }}.QueryWithOptions(fn)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	q.ServeHTTP(rec, req)
+
+	if err == nil || err.Error() != "connection lost" {
+		t.Errorf("error callback got %v, want: connection lost", err)
+	}
+
+	if got, want := rec.Body.String(), `[{"Id":1,"Name":""}`+"\n"; got != want {
+		t.Errorf("body = %#v, want: %#v", got, want)
+	}
+
+	if got, want := rec.Result().Trailer.Get("X-Stream-Error"), "Internal Server Error"; got != want {
+		t.Errorf("X-Stream-Error = %#v, want: %#v", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	q.SetEncoder(NewJSONEnvelope).ServeHTTP(rec, req)
+
+	if got, want := rec.Body.String(), `{"data":[{"Id":1,"Name":""}`+"\n"+`],"meta":{"limit":0,"offset":0,"count":1,"errors":["Internal Server Error"]}`+"\n}"; got != want {
+		t.Errorf("body = %#v, want: %#v", got, want)
+	}
+}
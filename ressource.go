@@ -2,26 +2,78 @@ package wsi
 
 import (
 	"net/http"
+	"time"
 )
 
-// QueryFunc makes the sql query and returns a Scanner. If an error is returned, QueryFunc must write
-// to the reponsewriter (set the status code etc). If no error is returned QueryFunc must not write
+// QueryFunc makes the sql query and returns a Scanner. The parameters of the path are available via PathParams. If an error is returned, QueryFunc may write
+// to the reponsewriter (set the status code etc). If it did not, the response is written by the ErrorMapper
+// of the Query (see DefaultErrorMapper). If no error is returned QueryFunc must not write
 // to the response write. specific headers are the exception and may be set.
+// Since a QueryFunc only gets the limit and offset, sort, fields and filter query values are neither checked
+// nor applied; use a QueryOptionsFunc for them.
 type QueryFunc func(limit, offset int, w http.ResponseWriter, r *http.Request) (Scanner, error)
 
+// QueryOptionsFunc is like QueryFunc but gets all QueryOptions of the request, e.g. the sorting.
+// The options are already checked against the columns of the ressource.
+type QueryOptionsFunc func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error)
+
+func (q QueryFunc) withOptions() QueryOptionsFunc {
+	return func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return q(options.Limit, options.Offset, w, r)
+	}
+}
+
 // ExecFunc makes the sql exec and writes to the response writer. If must return an error, if
-// some happened, so that the error may be passed to the general error handler
+// some happened, so that the error may be passed to the general error handler.
+// If nothing has been written for the error, the response is written by the ErrorMapper
+// of the Exec (see DefaultErrorMapper).
+// The parameters of the path (see PathParams) are part of the map.
+// For PATCH requests only the columns that are present in the body are part of the map, if the
+// RequestDecoder is a PatchDecoder (like JSONDecoder). Columns that should be cleared are nil.
 type ExecFunc func(map[string]interface{}, http.ResponseWriter, *http.Request) error
 
 type Ressource struct {
 	RessourceFunc func() interface{}
 	ErrorHandler  func(r *http.Request, err error)
+
+	// ErrorMapper is the ErrorMapper of the handlers of the ressource (see Query.SetErrorMapper),
+	// if it is nil, DefaultErrorMapper is used
+	ErrorMapper ErrorMapper
+
+	// Timeout is the maximal duration of the requests of the handlers of the ressource, see Query.SetTimeout.
+	// 0 means no timeout.
+	Timeout time.Duration
+
+	// Unavailable classifies errors as unavailability of the database, see Query.SetUnavailable
+	Unavailable func(error) bool
+}
+
+// errorHandling returns the error handling of the handlers of the ressource
+func (rs Ressource) errorHandling() errorHandling {
+	return errorHandling{
+		errorHandler: rs.ErrorHandler,
+		errorMapper:  rs.ErrorMapper,
+		timeout:      rs.Timeout,
+		unavailable:  rs.Unavailable,
+	}
 }
 
 func (rs Ressource) ServeQuery(q QueryFunc, w http.ResponseWriter, r *http.Request) {
 	rs.Query(q).ServeHTTP(w, r)
 }
 
+func (rs Ressource) ServeQueryWithOptions(q QueryOptionsFunc, w http.ResponseWriter, r *http.Request) {
+	rs.QueryWithOptions(q).ServeHTTP(w, r)
+}
+
+func (rs Ressource) ServeGet(g GetFunc, w http.ResponseWriter, r *http.Request) {
+	rs.Get(g).ServeHTTP(w, r)
+}
+
+func (rs Ressource) ServeDelete(d DeleteFunc, w http.ResponseWriter, r *http.Request) {
+	rs.Delete(d).ServeHTTP(w, r)
+}
+
 func (rs Ressource) ServeExec(e ExecFunc, w http.ResponseWriter, r *http.Request) {
 	rs.Exec(e).ServeHTTP(w, r)
 }
@@ -30,20 +82,38 @@ func (rs Ressource) Exec(e ExecFunc) Exec {
 	if e == nil {
 		panic("ExecFunc can't be nil")
 	}
-	ee := Exec{mapperFn: rs.RessourceFunc, fn: e, dec: JSONDecoder}
-	if rs.ErrorHandler != nil {
-		ee = ee.SetErrorCallback(rs.ErrorHandler)
-	}
-	return ee
+	return Exec{mapperFn: rs.RessourceFunc, fn: e, dec: JSONDecoder, errorHandling: rs.errorHandling()}
 }
 
 func (rs Ressource) Query(q QueryFunc) Query {
 	if q == nil {
 		panic("QueryFunc can't be nil")
 	}
-	qq := Query{encFn: NewJSONStreamer, mapperFn: rs.RessourceFunc, fn: q}
-	if rs.ErrorHandler != nil {
-		qq = qq.SetErrorCallback(rs.ErrorHandler)
-	}
+	qq := rs.QueryWithOptions(q.withOptions())
+	qq.legacy = true
 	return qq
 }
+
+// QueryWithOptions is like Query but for a QueryOptionsFunc
+func (rs Ressource) QueryWithOptions(q QueryOptionsFunc) Query {
+	if q == nil {
+		panic("QueryOptionsFunc can't be nil")
+	}
+	return Query{encFn: NewJSONStreamer, mapperFn: rs.RessourceFunc, fn: q, errorHandling: rs.errorHandling()}
+}
+
+// Get returns a http.Handler that serves a single row as json object
+func (rs Ressource) Get(g GetFunc) Get {
+	if g == nil {
+		panic("GetFunc can't be nil")
+	}
+	return Get{mapperFn: rs.RessourceFunc, fn: g, errorHandling: rs.errorHandling()}
+}
+
+// Delete returns a http.Handler that deletes the row with the key of the request, see LastPathSegment
+func (rs Ressource) Delete(d DeleteFunc) Delete {
+	if d == nil {
+		panic("DeleteFunc can't be nil")
+	}
+	return Delete{mapperFn: rs.RessourceFunc, fn: d, key: LastPathSegment, errorHandling: rs.errorHandling()}
+}
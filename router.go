@@ -0,0 +1,170 @@
+package wsi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Routes are the functions of a Router. Every function is optional.
+type Routes struct {
+	// List serves GET on the collection
+	List QueryOptionsFunc
+
+	// Create serves POST on the collection
+	Create ExecFunc
+
+	// Get serves GET on an item
+	Get GetFunc
+
+	// Replace serves PUT on an item
+	Replace ExecFunc
+
+	// Patch serves PATCH on an item
+	Patch ExecFunc
+
+	// Delete serves DELETE on an item
+	Delete DeleteFunc
+}
+
+// Router is a http.Handler that serves the collection of a ressource at Path (e.g. /person/)
+// and the items that match the Item pattern (e.g. /person/{id:int}). It dispatches on the request method,
+// answers OPTIONS and writes http.StatusMethodNotAllowed for methods that have no handler.
+// The handlers may be replaced, e.g. to set limits of the List query.
+// The parameters of the Item pattern are passed to the item handlers, see PathParams. If the Router has been
+// created by Ressource.Router, string parameters are converted to the type of the field of their column
+// (e.g. the parameter id of the default pattern to the int field Id); if that fails, http.StatusNotFound is written.
+type Router struct {
+	Path string
+
+	// Item is the pattern of the items. If it has no segments, every path that is Path followed by
+	// a single segment is an item.
+	Item Pattern
+
+	List    http.Handler
+	Create  http.Handler
+	Get     http.Handler
+	Replace http.Handler
+	Patch   http.Handler
+	Delete  http.Handler
+
+	mapperFn func() interface{}
+}
+
+// Router returns a Router for the given path and the handlers for the given routes.
+// The path is either the path of the collection (e.g. /person/), then the items are matched by
+// the pattern path + "{id}", or the pattern of the items (e.g. /person/{id:int}), then the collection
+// is served at the path without the last segment. Router panics, if the pattern is invalid.
+func (rs Ressource) Router(path string, routes Routes) Router {
+	item := path
+	if i := strings.LastIndex(path, "/"); strings.HasPrefix(path[i+1:], "{") {
+		path = path[:i+1]
+	} else {
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+		item = path + "{id}"
+	}
+	rt := Router{Path: path, Item: MustParsePattern(item), mapperFn: rs.RessourceFunc}
+	if routes.List != nil {
+		rt.List = rs.QueryWithOptions(routes.List)
+	}
+	if routes.Create != nil {
+		rt.Create = rs.Exec(routes.Create)
+	}
+	if routes.Get != nil {
+		rt.Get = rs.Get(routes.Get)
+	}
+	if routes.Replace != nil {
+		rt.Replace = rs.Exec(routes.Replace)
+	}
+	if routes.Patch != nil {
+		rt.Patch = rs.Exec(routes.Patch)
+	}
+	if routes.Delete != nil {
+		rt.Delete = rs.Delete(routes.Delete)
+	}
+	return rt
+}
+
+// methods returns the handlers by method for the collection or the items
+func (rt Router) methods(item bool) map[string]http.Handler {
+	m := map[string]http.Handler{}
+	add := func(method string, h http.Handler) {
+		if h != nil {
+			m[method] = h
+		}
+	}
+	if item {
+		add("GET", rt.Get)
+		add("HEAD", rt.Get)
+		add("PUT", rt.Replace)
+		add("PATCH", rt.Patch)
+		add("DELETE", rt.Delete)
+		return m
+	}
+	add("GET", rt.List)
+	add("HEAD", rt.List)
+	add("POST", rt.Create)
+	return m
+}
+
+// allow returns the value of the Allow header for the given handlers
+func allow(methods map[string]http.Handler) string {
+	var allowed []string
+	for _, method := range []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"} {
+		if _, ok := methods[method]; ok {
+			allowed = append(allowed, method)
+		}
+	}
+	return strings.Join(append(allowed, "OPTIONS"), ", ")
+}
+
+// matchItem returns the parameters of the given path, if it is an item of the collection.
+// ok is false, if it is neither the collection nor an item.
+func (rt Router) matchItem(path string) (params map[string]interface{}, ok bool) {
+	if path == rt.Path {
+		return nil, true
+	}
+	if len(rt.Item.segments) > 0 {
+		return rt.Item.Match(path)
+	}
+	if !strings.HasPrefix(path, rt.Path) {
+		return nil, false
+	}
+	key := path[len(rt.Path):]
+	if key == "" || strings.Contains(key, "/") {
+		return nil, false
+	}
+	return map[string]interface{}{}, true
+}
+
+func (rt Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params, ok := rt.matchItem(r.URL.Path)
+	if ok && len(params) > 0 && rt.mapperFn != nil {
+		var err error
+		params, err = convertPathParams(params, rt.mapperFn())
+		ok = err == nil
+	}
+	if !ok {
+		ServeProblem(NewProblem(http.StatusNotFound, ""), w, r)
+		return
+	}
+
+	methods := rt.methods(params != nil)
+	if params != nil {
+		r = withPathParams(r, params)
+	}
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Allow", allow(methods))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h, has := methods[r.Method]
+	if !has {
+		w.Header().Set("Allow", allow(methods))
+		ServeProblem(NewProblem(http.StatusMethodNotAllowed, ""), w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
@@ -0,0 +1,114 @@
+package wsi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	var called string
+	rt := Ressource{RessourceFunc: newPersonMapper}.Router("/person", Routes{
+		List: func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			called = "list"
+			return NewTestQuery([]string{"Id", "Name"}), nil
+		},
+		Get: func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			called = "get"
+			return NewTestQuery([]string{"Id", "Name"}, map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian")}), nil
+		},
+		Delete: func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+			called = "delete " + key
+			return 1, nil
+		},
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+		called       string
+		allow        string
+	}{
+		{"GET", "/person/", 200, "list", ""},
+		{"GET", "/person/12", 200, "get", ""},
+		{"DELETE", "/person/12", 204, "delete 12", ""},
+		{"POST", "/person/", 405, "", "GET, HEAD, OPTIONS"},
+		{"PUT", "/person/12", 405, "", "GET, HEAD, DELETE, OPTIONS"},
+		{"OPTIONS", "/person/12", 204, "", "GET, HEAD, DELETE, OPTIONS"},
+		{"OPTIONS", "/person/", 204, "", "GET, HEAD, OPTIONS"},
+		{"GET", "/person/12/books", 404, "", ""},
+		{"GET", "/book/", 404, "", ""},
+	}
+
+	for i, test := range tests {
+		called = ""
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if called != test.called {
+			t.Errorf("[%d] called = %#v, want: %#v", i, called, test.called)
+		}
+
+		if got := rec.Header().Get("Allow"); got != test.allow {
+			t.Errorf("[%d] Allow = %#v, want: %#v", i, got, test.allow)
+		}
+	}
+}
+
+func TestRouterDefaultItemParam(t *testing.T) {
+	var got map[string]interface{}
+	var called string
+	rt := Ressource{RessourceFunc: newPersonData}.Router("/person", Routes{
+		Get: func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			called = "get"
+			return NewTestQuery([]string{"Id", "Name"}, map[string]Setter{"Id": SetInt(12), "Name": SetString("Adrian")}), nil
+		},
+		Replace: func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+			called = "replace"
+			got = m
+			return nil
+		},
+		Delete: func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+			called = "delete"
+			return 1, nil
+		},
+	})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		called             string
+		m                  map[string]interface{}
+	}{
+		{"PUT", "/person/12", `{"Id":99,"Name":"Adrian"}`, 200, "replace", map[string]interface{}{"Id": 12, "Name": "Adrian"}},
+		{"PUT", "/person/abc", `{"Id":99,"Name":"Adrian"}`, 404, "", nil},
+		{"GET", "/person/abc", "", 404, "", nil},
+		{"DELETE", "/person/abc", "", 404, "", nil},
+	}
+
+	for i, test := range tests {
+		got, called = nil, ""
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		if called != test.called {
+			t.Errorf("[%d] called = %#v, want: %#v", i, called, test.called)
+		}
+
+		if !reflect.DeepEqual(got, test.m) {
+			t.Errorf("[%d] map = %#v, want: %#v", i, got, test.m)
+		}
+	}
+}
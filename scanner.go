@@ -87,6 +87,7 @@ func (e errScanner) Close() error                      { return nil }
 // so that the call is independant from the position of the returned columns
 type dbScanner struct {
 	columns map[string]int
+	cols    []string
 	*sql.Rows
 	err    error
 	closed bool
@@ -95,15 +96,14 @@ type dbScanner struct {
 // Error returns the first error that did happen
 func (sc *dbScanner) Error() error { return sc.err }
 
-func (sc *dbScanner) Columns() (cols []string) {
-	if sc.err != nil {
-		return
-	}
-	cols, sc.err = sc.Rows.Columns()
-	return
+// Columns returns the columns of the rows. They are read when the scanner is created,
+// so that they are still available after the rows have been closed, e.g. for the cursor of the last row.
+func (sc *dbScanner) Columns() []string {
+	return sc.cols
 }
 
-// Next returns false if there are no rows left or if any error happened before
+// Next returns false if there are no rows left or if any error happened before.
+// If the iteration stopped because of an error, it is returned by Error.
 func (sc *dbScanner) Next() bool {
 	if sc.err != nil {
 		if !sc.closed {
@@ -112,7 +112,11 @@ func (sc *dbScanner) Next() bool {
 		}
 		return false
 	}
-	return sc.Rows.Next()
+	if sc.Rows.Next() {
+		return true
+	}
+	sc.err = sc.Rows.Err()
+	return false
 }
 
 // Scan allows scanning by column name instead of column position
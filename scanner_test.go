@@ -1 +1,178 @@
 package wsi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// failingDriver returns rows with a single column "Id" that fail after the given number of rows.
+// If eof is true, the rows end without error instead.
+type failingDriver struct{ eof bool }
+
+func (d failingDriver) Open(string) (driver.Conn, error) { return failingConn{d.eof}, nil }
+
+type failingConn struct{ eof bool }
+
+func (c failingConn) Prepare(q string) (driver.Stmt, error) { return failingStmt{c.eof}, nil }
+func (failingConn) Close() error                            { return nil }
+func (failingConn) Begin() (driver.Tx, error)               { return nil, errors.New("not supported") }
+
+type failingStmt struct{ eof bool }
+
+func (failingStmt) Close() error  { return nil }
+func (failingStmt) NumInput() int { return 1 }
+func (failingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s failingStmt) Query(v []driver.Value) (driver.Rows, error) {
+	return &failingRows{after: v[0].(int64), eof: s.eof}, nil
+}
+
+type failingRows struct {
+	after, n int64
+	eof      bool
+}
+
+func (f *failingRows) Columns() []string { return []string{"Id"} }
+func (f *failingRows) Close() error      { return nil }
+
+func (f *failingRows) Next(dest []driver.Value) error {
+	if f.n >= f.after {
+		if f.eof {
+			return io.EOF
+		}
+		return errors.New("connection lost")
+	}
+	f.n++
+	dest[0] = f.n
+	return nil
+}
+
+func init() {
+	sql.Register("wsi_failing", failingDriver{})
+	sql.Register("wsi_rows", failingDriver{eof: true})
+}
+
+func TestDBScannerRowsErr(t *testing.T) {
+	db, err := sql.Open("wsi_failing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sc, err := DBQuery(db, "SELECT", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows int
+	for sc.Next() {
+		rows++
+	}
+
+	if rows != 2 {
+		t.Errorf("got %d rows, want: 2", rows)
+	}
+
+	if err := sc.Error(); err == nil || err.Error() != "connection lost" {
+		t.Errorf("Error() = %v, want: connection lost", err)
+	}
+
+	var cbErr error
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return DBQuery(db, "SELECT", 1)
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { cbErr = e }}.ServeQueryWithOptions(fn, rec, req)
+
+	if cbErr == nil || cbErr.Error() != "connection lost" {
+		t.Errorf("error callback got %v, want: connection lost", cbErr)
+	}
+
+	if got, want := rec.Result().Trailer.Get("X-Stream-Error"), "Internal Server Error"; got != want {
+		t.Errorf("X-Stream-Error = %#v, want: %#v", got, want)
+	}
+}
+
+func TestDBQueryContextCanceled(t *testing.T) {
+	db, err := sql.Open("wsi_failing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sc, err := DBQueryContext(ctx, db, "SELECT", int64(1)<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sc.Next() {
+		t.Fatalf("expected a first row")
+	}
+
+	cancel()
+
+	for sc.Next() {
+	}
+
+	if err := sc.Error(); err != context.Canceled {
+		t.Errorf("Error() = %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	var hasDeadline bool
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		_, hasDeadline = r.Context().Deadline()
+		return NewTestQuery([]string{"Id"}), nil
+	}
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).SetTimeout(time.Second).ServeHTTP(rec, req)
+
+	if !hasDeadline {
+		t.Errorf("context of the request passed to the QueryOptionsFunc should have a deadline")
+	}
+}
+
+func TestQueryCursorDB(t *testing.T) {
+	db, err := sql.Open("wsi_rows", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return DBQuery(db, "SELECT", int64(options.Limit))
+	}
+
+	q := Ressource{RessourceFunc: newPersonMapper}.QueryWithOptions(fn).
+		SetLimits(Limits{Default: 2}).
+		SetCursor([]byte("secret"), SortField{Column: "Id"})
+
+	for _, conditional := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		q.SetConditional(conditional).ServeHTTP(rec, req)
+
+		if got := rec.Result().Trailer.Get("X-Stream-Error"); got != "" {
+			t.Errorf("conditional %v => X-Stream-Error = %#v, want none", conditional, got)
+		}
+
+		if rec.Result().Trailer.Get("X-Next-Cursor") == "" {
+			t.Errorf("conditional %v => missing X-Next-Cursor trailer", conditional)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package wsi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dialect defines how identifiers are quoted and how placeholders look for a database
+type Dialect struct {
+	// Quote quotes the given identifier
+	Quote func(ident string) string
+
+	// Placeholder returns the placeholder for the n-th argument, n starts with 1
+	Placeholder func(n int) string
+}
+
+var (
+	// Postgres quotes identifiers with double quotes and uses $1, $2... as placeholders
+	Postgres = Dialect{Quote: quoteWith(`"`), Placeholder: func(n int) string { return "$" + strconv.Itoa(n) }}
+
+	// MySQL quotes identifiers with backticks and uses ? as placeholders
+	MySQL = Dialect{Quote: quoteWith("`"), Placeholder: func(int) string { return "?" }}
+
+	// SQLite quotes identifiers with double quotes and uses ? as placeholders
+	SQLite = Dialect{Quote: quoteWith(`"`), Placeholder: func(int) string { return "?" }}
+)
+
+func quoteWith(q string) func(string) string {
+	return func(ident string) string {
+		return q + strings.Replace(ident, q, q+q, -1) + q
+	}
+}
+
+// Operator is a comparison operator of a Filter
+type Operator string
+
+const (
+	OpEq     Operator = "eq"
+	OpNe     Operator = "ne"
+	OpLt     Operator = "lt"
+	OpLte    Operator = "lte"
+	OpGt     Operator = "gt"
+	OpGte    Operator = "gte"
+	OpIn     Operator = "in"
+	OpLike   Operator = "like"
+	OpIsNull Operator = "isnull"
+)
+
+var comparisons = map[Operator]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpLt:   "<",
+	OpLte:  "<=",
+	OpGt:   ">",
+	OpGte:  ">=",
+	OpLike: "LIKE",
+}
+
+// Filter restricts the rows of a query to those where the column compares to the value.
+// For OpIn the Value must be a slice, for OpIsNull it must be a bool (false means IS NOT NULL).
+type Filter struct {
+	Column   string
+	Operator Operator
+	Value    interface{}
+}
+
+// SQLBuilder builds sql fragments out of QueryOptions. Identifiers are quoted and values are
+// passed as arguments, so that the fragments are safe to be put into a query.
+// Only the columns of the struct the builder was created for are allowed.
+// The arguments of all fragments are collected in the order of the calls, so the fragments
+// must be put into the query in the same order.
+type SQLBuilder struct {
+	Dialect Dialect
+	columns columns
+	args    []interface{}
+}
+
+// NewSQLBuilder returns a SQLBuilder for the given dialect that allows the columns of the struct structPtr points to
+// (see MapSQL).
+func NewSQLBuilder(d Dialect, structPtr interface{}) (*SQLBuilder, error) {
+	cols, err := sqlColumns(structPtr)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLBuilder{Dialect: d, columns: cols}, nil
+}
+
+// Args returns the arguments for all fragments that were build so far
+func (b *SQLBuilder) Args() []interface{} {
+	return b.args
+}
+
+// Arg adds the given value to the arguments and returns its placeholder
+func (b *SQLBuilder) Arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return b.Dialect.Placeholder(len(b.args))
+}
+
+// Column returns the quoted column or an error if the column is not allowed
+func (b *SQLBuilder) Column(col string) (string, error) {
+	if !b.columns.has(col) {
+		return "", fmt.Errorf("unknown column %#v", col)
+	}
+	return b.Dialect.Quote(col), nil
+}
+
+// Select returns the comma separated list of the given quoted columns. If no columns are given,
+// all columns are returned in the order of the struct fields.
+func (b *SQLBuilder) Select(fields []string) (string, error) {
+	if len(fields) == 0 {
+		for _, c := range b.columns.ordered() {
+			fields = append(fields, c.Name)
+		}
+	}
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		col, err := b.Column(f)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = col
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// OrderBy returns the ORDER BY clause (with a leading space) for the given sort fields
+// or an empty string if there are none.
+func (b *SQLBuilder) OrderBy(sort []SortField) (string, error) {
+	if len(sort) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		col, err := b.Column(s.Column)
+		if err != nil {
+			return "", err
+		}
+		if s.Desc {
+			parts[i] = col + " DESC"
+		} else {
+			parts[i] = col + " ASC"
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+// Where returns the WHERE clause (with a leading space) for the given filters combined with AND
+// or an empty string if there are none. The values of the filters are added to the arguments.
+func (b *SQLBuilder) Where(filters []Filter) (string, error) {
+	if len(filters) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(filters))
+	for i, f := range filters {
+		cond, err := b.condition(f)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = cond
+	}
+	return " WHERE " + strings.Join(parts, " AND "), nil
+}
+
+// WhereOptions is like Where, but returns the WHERE clause for the filters and the cursor of the given options.
+// For the cursor a condition is added that only allows the rows after the cursor in the order of its sort fields.
+func (b *SQLBuilder) WhereOptions(options QueryOptions) (string, error) {
+	where, err := b.Where(options.Filter)
+	if err != nil || options.Cursor == nil {
+		return where, err
+	}
+
+	cond, err := b.after(options.Cursor)
+	if err != nil {
+		return "", err
+	}
+
+	if where == "" {
+		return " WHERE " + cond, nil
+	}
+	return where + " AND " + cond, nil
+}
+
+// after returns the condition for the rows that follow the cursor, e.g. for the sorting +a,-b
+//   ("a" > $1 OR ("a" = $2 AND "b" < $3))
+func (b *SQLBuilder) after(c *Cursor) (string, error) {
+	if len(c.Sort) == 0 || len(c.Sort) != len(c.Values) {
+		return "", fmt.Errorf("cursor must have a value for each sort field")
+	}
+
+	ors := make([]string, len(c.Sort))
+	for i, s := range c.Sort {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			col, err := b.Column(c.Sort[j].Column)
+			if err != nil {
+				return "", err
+			}
+			ands = append(ands, col+" = "+b.Arg(c.Values[j]))
+		}
+		col, err := b.Column(s.Column)
+		if err != nil {
+			return "", err
+		}
+		cmp := " > "
+		if s.Desc {
+			cmp = " < "
+		}
+		ands = append(ands, col+cmp+b.Arg(c.Values[i]))
+		if len(ands) > 1 {
+			ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+		} else {
+			ors[i] = ands[0]
+		}
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", nil
+}
+
+func (b *SQLBuilder) condition(f Filter) (string, error) {
+	col, err := b.Column(f.Column)
+	if err != nil {
+		return "", err
+	}
+
+	switch f.Operator {
+	case OpIsNull:
+		isNull, ok := f.Value.(bool)
+		if !ok {
+			return "", fmt.Errorf("value for %s of column %#v must be a bool", f.Operator, f.Column)
+		}
+		if isNull {
+			return col + " IS NULL", nil
+		}
+		return col + " IS NOT NULL", nil
+	case OpIn:
+		v := reflect.ValueOf(f.Value)
+		if v.Kind() != reflect.Slice || v.Len() == 0 {
+			return "", fmt.Errorf("value for %s of column %#v must be a non empty slice", f.Operator, f.Column)
+		}
+		phs := make([]string, v.Len())
+		for i := range phs {
+			phs[i] = b.Arg(v.Index(i).Interface())
+		}
+		return col + " IN (" + strings.Join(phs, ",") + ")", nil
+	default:
+		cmp, ok := comparisons[f.Operator]
+		if !ok {
+			return "", fmt.Errorf("unknown operator %#v", string(f.Operator))
+		}
+		return col + " " + cmp + " " + b.Arg(f.Value), nil
+	}
+}
@@ -0,0 +1,123 @@
+package wsi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLBuilder(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		sort    []SortField
+		filters []Filter
+		where   string
+		orderBy string
+		args    []interface{}
+	}{
+		{Postgres, nil, nil, "", "", nil},
+		{
+			Postgres,
+			[]SortField{{"Name", false}, {"Age", true}},
+			[]Filter{{"Name", OpLike, "A%"}, {"Id", OpIn, []int{1, 2}}, {"Notes", OpIsNull, false}},
+			` WHERE "Name" LIKE $1 AND "Id" IN ($2,$3) AND "Notes" IS NOT NULL`,
+			` ORDER BY "Name" ASC, "Age" DESC`,
+			[]interface{}{"A%", 1, 2},
+		},
+		{
+			MySQL,
+			[]SortField{{"Id", true}},
+			[]Filter{{"Age", OpGte, 30}, {"Name", OpNe, "x"}},
+			" WHERE `Age` >= ? AND `Name` <> ?",
+			" ORDER BY `Id` DESC",
+			[]interface{}{30, "x"},
+		},
+	}
+
+	for _, test := range tests {
+		b, err := NewSQLBuilder(test.dialect, &person{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		where, err := b.Where(test.filters)
+		if err != nil {
+			t.Errorf("Where(%v) returned error: %s", test.filters, err)
+		}
+
+		if where != test.where {
+			t.Errorf("Where(%v) = %#v, want: %#v", test.filters, where, test.where)
+		}
+
+		orderBy, err := b.OrderBy(test.sort)
+		if err != nil {
+			t.Errorf("OrderBy(%v) returned error: %s", test.sort, err)
+		}
+
+		if orderBy != test.orderBy {
+			t.Errorf("OrderBy(%v) = %#v, want: %#v", test.sort, orderBy, test.orderBy)
+		}
+
+		if !reflect.DeepEqual(b.Args(), test.args) {
+			t.Errorf("Args() = %#v, want: %#v", b.Args(), test.args)
+		}
+	}
+}
+
+func TestSQLBuilderUnknownColumn(t *testing.T) {
+	b, _ := NewSQLBuilder(Postgres, &person{})
+
+	if _, err := b.OrderBy([]SortField{{`Name"; DROP TABLE person; --`, false}}); err == nil {
+		t.Errorf("OrderBy with unknown column should return an error")
+	}
+
+	if _, err := b.Where([]Filter{{"err", OpEq, 1}}); err == nil {
+		t.Errorf("Where with unexported field should return an error")
+	}
+}
+
+func TestSQLBuilderSelect(t *testing.T) {
+	b, _ := NewSQLBuilder(Postgres, &person{})
+
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{nil, `"Id","Name","Age","Notes"`},
+		{[]string{"Name", "Id"}, `"Name","Id"`},
+	}
+
+	for _, test := range tests {
+		got, err := b.Select(test.fields)
+		if err != nil {
+			t.Errorf("Select(%v) returned error: %s", test.fields, err)
+		}
+		if got != test.want {
+			t.Errorf("Select(%v) = %#v, want: %#v", test.fields, got, test.want)
+		}
+	}
+
+	if _, err := b.Select([]string{"unknown"}); err == nil {
+		t.Errorf("Select with unknown column should return an error")
+	}
+}
+
+func TestSQLBuilderWhereOptions(t *testing.T) {
+	b, _ := NewSQLBuilder(Postgres, &person{})
+
+	where, err := b.WhereOptions(QueryOptions{
+		Filter: []Filter{{"Age", OpGt, 30}},
+		Cursor: &Cursor{Sort: []SortField{{"Name", false}, {"Id", true}}, Values: []interface{}{"Adrian", 12}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ` WHERE "Age" > $1 AND ("Name" > $2 OR ("Name" = $3 AND "Id" < $4))`; where != want {
+		t.Errorf("WhereOptions() = %#v, want: %#v", where, want)
+	}
+
+	if want := []interface{}{30, "Adrian", "Adrian", 12}; !reflect.DeepEqual(b.Args(), want) {
+		t.Errorf("Args() = %#v, want: %#v", b.Args(), want)
+	}
+}
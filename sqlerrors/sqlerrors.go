@@ -0,0 +1,248 @@
+/*
+Package sqlerrors decodes constraint violations reported by database drivers into a driver neutral ConstraintError.
+
+The errors of the following drivers are recognized by their codes, without importing the drivers:
+
+	github.com/lib/pq, github.com/go-on/pq (and every error with a SQLState() method, e.g. github.com/jackc/pgx)
+	github.com/go-sql-driver/mysql
+	github.com/mattn/go-sqlite3
+*/
+package sqlerrors
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Kind is the kind of a constraint violation
+type Kind int
+
+const (
+	Unique Kind = iota + 1
+	ForeignKey
+	NotNull
+	Check
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Unique:
+		return "unique"
+	case ForeignKey:
+		return "foreign key"
+	case NotNull:
+		return "not null"
+	case Check:
+		return "check"
+	}
+	return "unknown"
+}
+
+// ConstraintError is a driver neutral constraint violation.
+// Constraint and Column are empty, if the driver did not report them.
+type ConstraintError struct {
+	Kind       Kind
+	Constraint string
+	Column     string
+
+	// Err is the original error of the driver
+	Err error
+}
+
+func (c *ConstraintError) Error() string {
+	return c.Kind.String() + " constraint violated: " + c.Err.Error()
+}
+
+func (c *ConstraintError) Unwrap() error {
+	return c.Err
+}
+
+// Decode returns the ConstraintError for the given error, or nil if the error (or any error it wraps)
+// is no constraint violation of a known driver.
+func Decode(err error) *ConstraintError {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if ce, ok := e.(*ConstraintError); ok {
+			return ce
+		}
+		if ce := decode(e); ce != nil {
+			ce.Err = e
+			return ce
+		}
+	}
+	return nil
+}
+
+type sqlStater interface {
+	SQLState() string
+}
+
+func decode(err error) *ConstraintError {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Struct {
+		switch v.Type().Name() {
+		case "MySQLError":
+			if n := v.FieldByName("Number"); n.IsValid() && isUint(n) {
+				return decodeMySQL(n.Uint(), stringField(v, "Message"))
+			}
+		case "Error", "PgError":
+			if c := v.FieldByName("Code"); c.IsValid() && c.Kind() == reflect.String {
+				return decodePostgres(c.String(), v)
+			}
+			if c, ext := v.FieldByName("Code"), v.FieldByName("ExtendedCode"); c.IsValid() && ext.IsValid() && isInt(c) && isInt(ext) {
+				return decodeSQLite(c.Int(), ext.Int(), err.Error())
+			}
+		}
+	}
+
+	if s, ok := err.(sqlStater); ok {
+		return decodePostgres(s.SQLState(), v)
+	}
+	return nil
+}
+
+var postgresKinds = map[string]Kind{
+	"23505": Unique,
+	"23503": ForeignKey,
+	"23502": NotNull,
+	"23514": Check,
+}
+
+// the detail of unique and foreign key violations, e.g. Key (email)=(a@b.c) already exists.
+var postgresKeyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// decodePostgres decodes the SQLSTATE code and the fields Constraint, Column and Detail (lib/pq, go-on/pq)
+// or ConstraintName and ColumnName (pgx) of the given error value
+func decodePostgres(code string, v reflect.Value) *ConstraintError {
+	kind, ok := postgresKinds[code]
+	if !ok {
+		return nil
+	}
+	ce := &ConstraintError{
+		Kind:       kind,
+		Constraint: stringField(v, "Constraint", "ConstraintName"),
+		Column:     stringField(v, "Column", "ColumnName"),
+	}
+	if ce.Column == "" {
+		if m := postgresKeyDetail.FindStringSubmatch(stringField(v, "Detail")); m != nil {
+			ce.Column = m[1]
+		}
+	}
+	return ce
+}
+
+var (
+	// Duplicate entry 'a@b.c' for key 'email' (or 'person.email' since MySQL 8)
+	mysqlDuplicate = regexp.MustCompile(`for key '([^']+)'`)
+
+	// ... a foreign key constraint fails (`db`.`book`, CONSTRAINT `fk_author` FOREIGN KEY (`author_id`) REFERENCES ...
+	mysqlForeignKey = regexp.MustCompile("CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`\\)")
+
+	// Column 'name' cannot be null
+	mysqlNotNull = regexp.MustCompile(`Column '([^']+)' cannot be null`)
+
+	// Check constraint 'age_positive' is violated.
+	mysqlCheck = regexp.MustCompile(`Check constraint '([^']+)' is violated`)
+)
+
+// decodeMySQL decodes the error number and message of a MySQLError
+func decodeMySQL(number uint64, msg string) *ConstraintError {
+	switch number {
+	case 1062: // ER_DUP_ENTRY
+		ce := &ConstraintError{Kind: Unique}
+		if m := mysqlDuplicate.FindStringSubmatch(msg); m != nil {
+			ce.Constraint = m[1][strings.LastIndex(m[1], ".")+1:]
+		}
+		return ce
+	case 1451, 1452: // ER_ROW_IS_REFERENCED_2, ER_NO_REFERENCED_ROW_2
+		ce := &ConstraintError{Kind: ForeignKey}
+		if m := mysqlForeignKey.FindStringSubmatch(msg); m != nil {
+			ce.Constraint, ce.Column = m[1], m[2]
+		}
+		return ce
+	case 1048: // ER_BAD_NULL_ERROR
+		ce := &ConstraintError{Kind: NotNull}
+		if m := mysqlNotNull.FindStringSubmatch(msg); m != nil {
+			ce.Column = m[1]
+		}
+		return ce
+	case 3819: // ER_CHECK_CONSTRAINT_VIOLATED
+		ce := &ConstraintError{Kind: Check}
+		if m := mysqlCheck.FindStringSubmatch(msg); m != nil {
+			ce.Constraint = m[1]
+		}
+		return ce
+	}
+	return nil
+}
+
+var sqliteKinds = map[int64]Kind{
+	2067: Unique,     // SQLITE_CONSTRAINT_UNIQUE
+	1555: Unique,     // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  ForeignKey, // SQLITE_CONSTRAINT_FOREIGNKEY
+	1299: NotNull,    // SQLITE_CONSTRAINT_NOTNULL
+	275:  Check,      // SQLITE_CONSTRAINT_CHECK
+}
+
+// decodeSQLite decodes the extended code and the message of a sqlite3.Error, e.g.
+//   UNIQUE constraint failed: person.email
+//   CHECK constraint failed: age_positive
+func decodeSQLite(code, extended int64, msg string) *ConstraintError {
+	if code != 19 { // SQLITE_CONSTRAINT
+		return nil
+	}
+	kind, ok := sqliteKinds[extended]
+	if !ok {
+		return nil
+	}
+	ce := &ConstraintError{Kind: kind}
+	i := strings.Index(msg, "constraint failed: ")
+	if i < 0 {
+		return ce
+	}
+	target := msg[i+len("constraint failed: "):]
+	switch kind {
+	case Check:
+		ce.Constraint = target
+	default:
+		// multi column constraints are reported as person.a, person.b
+		target = strings.Split(target, ",")[0]
+		ce.Column = target[strings.LastIndex(target, ".")+1:]
+	}
+	return ce
+}
+
+// stringField returns the value of the first of the given string fields of the struct value v that is not empty
+func stringField(v reflect.Value, names ...string) string {
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, name := range names {
+		f := v.FieldByName(name)
+		if f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package sqlerrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-on/pq"
+)
+
+// MySQLError has the shape of github.com/go-sql-driver/mysql.MySQLError
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (m *MySQLError) Error() string { return fmt.Sprintf("Error %d: %s", m.Number, m.Message) }
+
+// Error has the shape of github.com/mattn/go-sqlite3.Error
+type Error struct {
+	Code         int
+	ExtendedCode int
+	err          string
+}
+
+func (e Error) Error() string { return e.err }
+
+type stateErr string
+
+func (s stateErr) Error() string    { return string(s) }
+func (s stateErr) SQLState() string { return string(s) }
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *ConstraintError
+	}{
+		{errors.New("x"), nil},
+		{pq.Error{Code: "42P01"}, nil},
+		{pq.Error{Code: "23505", Constraint: "person_email_key", Detail: "Key (email)=(a@b.c) already exists."}, &ConstraintError{Kind: Unique, Constraint: "person_email_key", Column: "email"}},
+		{fmt.Errorf("insert: %w", pq.Error{Code: "23503", Constraint: "book_author_fk", Detail: `Key (author_id)=(5) is not present in table "author".`}), &ConstraintError{Kind: ForeignKey, Constraint: "book_author_fk", Column: "author_id"}},
+		{pq.Error{Code: "23502", Column: "name"}, &ConstraintError{Kind: NotNull, Column: "name"}},
+		{stateErr("23514"), &ConstraintError{Kind: Check}},
+		{&MySQLError{1062, "Duplicate entry 'a@b.c' for key 'person.email'"}, &ConstraintError{Kind: Unique, Constraint: "email"}},
+		{&MySQLError{1452, "Cannot add or update a child row: a foreign key constraint fails (`db`.`book`, CONSTRAINT `fk_author` FOREIGN KEY (`author_id`) REFERENCES `author` (`id`))"}, &ConstraintError{Kind: ForeignKey, Constraint: "fk_author", Column: "author_id"}},
+		{&MySQLError{1048, "Column 'name' cannot be null"}, &ConstraintError{Kind: NotNull, Column: "name"}},
+		{&MySQLError{1146, "Table 'db.x' doesn't exist"}, nil},
+		{Error{19, 2067, "UNIQUE constraint failed: person.email"}, &ConstraintError{Kind: Unique, Column: "email"}},
+		{Error{19, 275, "CHECK constraint failed: age_positive"}, &ConstraintError{Kind: Check, Constraint: "age_positive"}},
+		{Error{19, 787, "FOREIGN KEY constraint failed"}, &ConstraintError{Kind: ForeignKey}},
+		{Error{1, 1, "SQL logic error"}, nil},
+	}
+
+	for _, test := range tests {
+		got := Decode(test.err)
+		if test.want == nil {
+			if got != nil {
+				t.Errorf("Decode(%v) = %#v, want: nil", test.err, got)
+			}
+			continue
+		}
+
+		if got == nil {
+			t.Errorf("Decode(%v) = nil, want: %#v", test.err, test.want)
+			continue
+		}
+
+		if got.Kind != test.want.Kind || got.Constraint != test.want.Constraint || got.Column != test.want.Column {
+			t.Errorf("Decode(%v) = %v/%#v/%#v, want: %v/%#v/%#v", test.err, got.Kind, got.Constraint, got.Column, test.want.Kind, test.want.Constraint, test.want.Column)
+		}
+
+		if got.Err == nil {
+			t.Errorf("Decode(%v).Err must be the original error", test.err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package wsi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// TimeoutError is passed to the error callback, if a QueryFunc or ExecFunc failed because the timeout
+// of the request was exceeded or because the database was unavailable (see Query.SetUnavailable).
+type TimeoutError struct {
+	// Timeout is the timeout of the request
+	Timeout time.Duration
+
+	// Unavailable is true, if the error was classified as unavailability of the database
+	Unavailable bool
+
+	// Err is the original error
+	Err error
+}
+
+func (t *TimeoutError) Error() string {
+	if t.Unavailable {
+		return "database unavailable: " + t.Err.Error()
+	}
+	return "timeout of " + t.Timeout.String() + " exceeded: " + t.Err.Error()
+}
+
+// Status returns http.StatusServiceUnavailable, if the database was unavailable and
+// http.StatusGatewayTimeout otherwise
+func (t *TimeoutError) Status() int {
+	if t.Unavailable {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusGatewayTimeout
+}
+
+// timeoutError returns a TimeoutError, if the given error happened because the deadline of the context of the
+// request was exceeded or if unavailable classifies it as unavailability of the database. Otherwise it returns nil.
+func timeoutError(r *http.Request, timeout time.Duration, unavailable func(error) bool, err error) *TimeoutError {
+	if err == nil {
+		return nil
+	}
+	if unavailable != nil && unavailable(err) {
+		return &TimeoutError{Timeout: timeout, Unavailable: true, Err: err}
+	}
+	if errors.Is(err, context.DeadlineExceeded) || r.Context().Err() == context.DeadlineExceeded {
+		return &TimeoutError{Timeout: timeout, Err: err}
+	}
+	return nil
+}
+
+// withTimeout returns the request with a context that is canceled after the given timeout
+// and the cancel function of the context. If timeout is 0, the request is returned unchanged.
+func withTimeout(r *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	if timeout <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return r.WithContext(ctx), cancel
+}
+
+// writeTracker is a http.ResponseWriter that tracks if something has been written
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (wt *writeTracker) WriteHeader(status int) {
+	wt.written = true
+	wt.ResponseWriter.WriteHeader(status)
+}
+
+func (wt *writeTracker) Write(b []byte) (int, error) {
+	wt.written = true
+	return wt.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying ResponseWriter, if it is a http.Flusher
+func (wt *writeTracker) Flush() {
+	if f, ok := wt.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,130 @@
+package wsi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errPoolExhausted = errors.New("too many connections")
+
+func isPoolExhausted(err error) bool { return err == errPoolExhausted }
+
+func TestQueryTimeoutStatus(t *testing.T) {
+	slow := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	}
+
+	exhausted := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return nil, errPoolExhausted
+	}
+
+	slowFirstRow := func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+		return NewTestScanner([]string{"Id"}, func(targets map[string]interface{}) (bool, error) {
+			<-r.Context().Done()
+			return true, r.Context().Err()
+		}), nil
+	}
+
+	tests := []struct {
+		fn          QueryOptionsFunc
+		status      int
+		unavailable bool
+	}{
+		{slow, http.StatusGatewayTimeout, false},
+		{exhausted, http.StatusServiceUnavailable, true},
+		{slowFirstRow, http.StatusGatewayTimeout, false},
+	}
+
+	for i, test := range tests {
+		var err error
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		Ressource{RessourceFunc: newPersonMapper, ErrorHandler: func(r *http.Request, e error) { err = e }}.
+			QueryWithOptions(test.fn).
+			SetTimeout(10*time.Millisecond).
+			SetUnavailable(isPoolExhausted).
+			ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("[%d] status = %v, want: %v", i, rec.Code, test.status)
+		}
+
+		te, ok := err.(*TimeoutError)
+		if !ok {
+			t.Errorf("[%d] error callback got %T, want: *TimeoutError", i, err)
+			continue
+		}
+
+		if te.Unavailable != test.unavailable {
+			t.Errorf("[%d] Unavailable = %v, want: %v", i, te.Unavailable, test.unavailable)
+		}
+	}
+}
+
+func TestExecTimeoutStatus(t *testing.T) {
+	slow := func(m map[string]interface{}, w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}
+
+	var err error
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"Name":"Peter"}`))
+	Ressource{RessourceFunc: newPersonData, ErrorHandler: func(r *http.Request, e error) { err = e }}.
+		Exec(slow).
+		SetTimeout(10*time.Millisecond).
+		ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %v, want: %v", rec.Code, http.StatusGatewayTimeout)
+	}
+
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Errorf("error callback got %T, want: *TimeoutError", err)
+	}
+}
+
+func TestRessourceTimeout(t *testing.T) {
+	rs := Ressource{
+		RessourceFunc: newPersonData,
+		Timeout:       10 * time.Millisecond,
+		Unavailable:   isPoolExhausted,
+	}
+	rt := rs.Router("/person", Routes{
+		List: func(options QueryOptions, w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			return nil, errPoolExhausted
+		},
+		Get: func(w http.ResponseWriter, r *http.Request) (Scanner, error) {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		},
+		Delete: func(key string, w http.ResponseWriter, r *http.Request) (int64, error) {
+			<-r.Context().Done()
+			return 0, r.Context().Err()
+		},
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/person/", http.StatusServiceUnavailable},
+		{"GET", "/person/12", http.StatusGatewayTimeout},
+		{"DELETE", "/person/12", http.StatusGatewayTimeout},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		rt.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%s %s => status = %v, want: %v", test.method, test.path, rec.Code, test.status)
+		}
+	}
+}